@@ -1,435 +1,97 @@
 package main
 
 import (
-	"bufio"
-	"bytes"
-	"encoding/csv"
-	"encoding/json"
+	"context"
+	"errors"
+	"flag"
 	"fmt"
-	"net/http"
+	"log/slog"
 	"os"
-	"os/exec"
-	"strconv"
-	"strings"
-	"sync"
-	"time"
-	"net"
-	// 导入viper读取配置
-	"github.com/spf13/viper"
-	"github.com/cheggaaa/pb/v3"
-)
-
-// 配置结构体
-type Config struct {
-    // zmap 扫描相关配置    
-    Port           int           `mapstructure:"port"`
-    InputFile      string        `mapstructure:"inputFile"` 
-    OutputFile     string        `mapstructure:"outputFile"`
-    Rate           int           `mapstructure:"rate"`
-    Bandwidth      string        `mapstructure:"bandwidth"`
-    // ollama 检测服务相关配置
-    MaxWorkers     int           `mapstructure:"maxWorkers"`
-    MaxIdleConns   int           `mapstructure:"maxIdleConns"`
-    Timeout        time.Duration `mapstructure:"timeout"`
-    IdleConnTimeout time.Duration `mapstructure:"idleConnTimeout"`
-    // ollama 性能测试相关配置
-    BenchPrompt    string        `mapstructure:"benchPrompt"`
-    BenchTimeout   time.Duration `mapstructure:"benchTimeout"`
-    // 中间文件配置
-    ScanOutputFile   string        `mapstructure:"scanOutputFile"`
-    OllamaOutputFile string        `mapstructure:"ollamaOutputFile"`
-}
-
-// 扫描器结构体
-type Scanner struct {
-    cfg        *Config
-    httpClient *http.Client
-    csvWriter  *csv.Writer
-    csvFile    *os.File
-    outputFile string
-    mu         sync.Mutex
-    progress   *pb.ProgressBar
-}
 
-// 初始化方法
-func NewScanner() (*Scanner, error) {
-    scanner := &Scanner{}
-    cfg := scanner.loadConfig()
-    scanner.cfg = cfg
-    
-    // 统一初始化HTTP客户端
-    scanner.httpClient = &http.Client{
-        Timeout: cfg.Timeout,
-        Transport: &http.Transport{
-            MaxIdleConns:    cfg.MaxIdleConns,
-            IdleConnTimeout: cfg.IdleConnTimeout,
-        },
-    }
-    
-    return scanner, nil
-}
-
-// 配置加载
-func (s *Scanner) loadConfig() *Config {
-	if err := viper.ReadInConfig(); err != nil {
-		fmt.Printf("⚠️ 配置文件读取失败: %v\n", err)
-	}
+	"github.com/rebecca554owen/scan/pkg/scan"
+)
 
-	var cfg Config
-	if err := viper.Unmarshal(&cfg); err != nil {
-		fmt.Printf("⚠️ 配置解析失败: %v\n", err)
-	}
-	return &cfg
-}
+// 构建信息，发布构建时通过 -ldflags "-X main.version=... -X main.commit=... -X main.date=..." 注入
+var version, commit, date string
 
-// 清理资源
-func (s *Scanner) Close() error {
-    var err error
-    if s.csvFile != nil {
-        s.csvWriter.Flush()
-        if closeErr := s.csvFile.Close(); closeErr != nil {
-            err = fmt.Errorf("关闭CSV文件失败: %w", closeErr)
-        }
-    }
-    
-    // 关闭HTTP客户端连接池
-    if s.httpClient != nil {
-        s.httpClient.CloseIdleConnections()
-    }
-    
-    // 进度条资源清理
-    if s.progress != nil {
-        s.progress.Finish()
+// 主函数
+func main() {
+    tui := flag.Bool("tui", false, "阶段运行期间显示终端面板")
+    mode := flag.String("mode", "", "非交互运行指定阶段后退出: scan、detect、scan-detect（边扫描边检测）、bench、embed（嵌入性能测试）、all（依次执行扫描、检测和性能测试）、check（只做运行前检查），为空时显示菜单")
+    configFile := flag.String("config", "", "配置文件路径，未指定时读取环境变量 SCAN_CONFIG，都为空时读取当前目录的 config.yaml")
+    dryRun := flag.Bool("dry-run", false, "只打印将要执行的扫描命令和前几个请求地址，不发送任何流量，等同于配置 dryRun: true")
+    limit := flag.Int("limit", 0, "服务检测和性能测试最多处理的目标数，用于快速试跑，大于0时覆盖配置 limit")
+    showVersion := flag.Bool("version", false, "打印版本号、提交和构建时间后退出")
+    flag.Parse()
+
+    if *showVersion {
+        fmt.Printf("scan %s\ncommit: %s\nbuilt: %s\n", orUnknown(version), orUnknown(commit), orUnknown(date))
+        return
     }
-    
-    return err
-}
-
-// 扫描IP地址
-func (s *Scanner) ScanIPs() error {
-    // 构建 zmap 命令参数
-    cmd := exec.Command("sudo", "zmap",
-        "-w", s.cfg.InputFile,
-        "-o", s.cfg.ScanOutputFile,
-        "-p", strconv.Itoa(s.cfg.Port),
-        "--rate", strconv.Itoa(s.cfg.Rate),
-        "-B", s.cfg.Bandwidth,
-    )
-    
-    // 打印完整命令
-    fmt.Printf("执行命令: %s\n", strings.Join(cmd.Args, " "))
-    
-    cmd.Stdout = os.Stdout
-    cmd.Stderr = os.Stderr
-
-    // 执行扫描命令
-    if err := cmd.Run(); err != nil {
-        return fmt.Errorf("zmap执行失败: %w", err)
+    // 请求的默认 User-Agent 带上版本号
+    if version != "" {
+        scan.Version = version
     }
 
-    return nil
-}
-
-// 获取模型名称
-func (s *Scanner) getModels(ip string) []string {
-    var models []string
-    modelsResp, err := s.httpClient.Get(fmt.Sprintf("http://%s:%d/api/tags", ip, s.cfg.Port))
-    if err != nil || modelsResp.StatusCode != http.StatusOK {
-        return models
-    }
-    defer modelsResp.Body.Close()
-    var data struct {
-        Models []struct {
-            Model string `json:"name"`
-        } `json:"models"`
-    }
-    
-    if err := json.NewDecoder(modelsResp.Body).Decode(&data); err == nil {
-        for _, m := range data.Models {
-            models = append(models, m.Model)
+    // 子命令
+    if flag.Arg(0) == "merge" {
+        if err := runMerge(flag.Args()[1:]); err != nil {
+            slog.Error("合并失败", "error", err)
+            os.Exit(1)
         }
+        return
     }
-    return models
-}
 
-// 服务检测
-func (s *Scanner) DetectOllama() error {
-    s.outputFile = s.cfg.OllamaOutputFile
-    
-    // 直接创建文件并写入表头
-    file, err := os.Create(s.outputFile)
-    if err != nil {
-        return fmt.Errorf("创建CSV文件失败: %w", err)
+    path := *configFile
+    if path == "" {
+        path = os.Getenv("SCAN_CONFIG")
     }
-    s.csvFile = file
-    s.csvWriter = csv.NewWriter(s.csvFile)
-    
-    if err := s.csvWriter.Write([]string{"IP地址", "端口", "模型名称"}); err != nil {
-        file.Close()
-        return fmt.Errorf("写入检测表头失败: %w", err)
+    cfg, err := scan.LoadConfig(path)
+    // 运行前检查不创建扫描器，配置无效时也要输出完整报告
+    if *mode == "check" {
+        if !runCheck(cfg, err) {
+            os.Exit(1)
+        }
+        return
     }
-    s.csvWriter.Flush()
-    
-    defer s.Close()
-    
-    ipsData, err := os.ReadFile(s.cfg.ScanOutputFile)
     if err != nil {
-        return fmt.Errorf("读取IP文件失败: %w", err)
+        slog.Error("初始化失败", "error", err)
+        os.Exit(1)
     }
-    ips := strings.Split(string(ipsData), "\n")
-    
-    if len(ips) == 0 {
-        return fmt.Errorf("未找到有效IP地址")
+    if *dryRun {
+        cfg.DryRun = true
     }
-    
-    workerPool := make(chan struct{}, s.cfg.MaxWorkers)
-    var wg sync.WaitGroup
-    var writeMu sync.Mutex
-    
-    // 初始化进度条
-    s.progress = pb.New(len(ips))
-    s.progress.SetTemplateString(`{{ "扫描进度:" }} {{counters . }} {{ bar . "[" "=" ">" "." "]" }} {{percent . }}`)
-    s.progress.Start()
-
-    for _, ip := range ips {
-        ip = strings.TrimSpace(ip)
-        if ip == "" {
-            continue
-        }
-        
-        workerPool <- struct{}{}
-        wg.Add(1)
-        
-        go func(ip string) {
-            defer func() {
-                <-workerPool
-                wg.Done()
-                s.progress.Increment()
-            }()
-
-            models := s.getModels(ip)
-            if len(models) > 0 {
-                fmt.Printf("✅ 发现可用服务: %s:%d 模型列表: %v\n", 
-                    ip, 
-                    s.cfg.Port,
-                    models)
-            }
-            writeMu.Lock()
-            defer writeMu.Unlock()
-            
-            if len(models) > 0 {
-                records := make([][]string, len(models))
-                for i, model := range models {
-                    records[i] = []string{
-                        ip,
-                        strconv.Itoa(s.cfg.Port),
-                        model,
-                    }
-                }
-                s.csvWriter.WriteAll(records)
-            }
-            s.csvWriter.Flush()
-        }(ip)
+    if *limit > 0 {
+        cfg.Limit = *limit
     }
-    
-    wg.Wait()
-    s.progress.Finish()
-    return nil
-}
-
-// 性能测试
-func (s *Scanner) BenchmarkOllama() error {
-    s.outputFile = s.cfg.OutputFile
-    
-    // 直接创建文件并写入表头
-    file, err := os.Create(s.outputFile)
+    logOutput, err := scan.SetupLogging(cfg)
     if err != nil {
-        return fmt.Errorf("创建CSV文件失败: %w", err)
+        slog.Error("初始化失败", "error", err)
+        os.Exit(1)
     }
-    s.csvFile = file
-    s.csvWriter = csv.NewWriter(s.csvFile)
-    
-    if err := s.csvWriter.Write([]string{"IP地址", "端口", "模型名称", "状态", "首Token延迟(ms)", "Tokens/s"}); err != nil {
-        file.Close()
-        return fmt.Errorf("写入测试表头失败: %w", err)
-    }
-    s.csvWriter.Flush()
-    
-    defer s.Close()
-    
-    // 读取服务检测结果
-    data, err := os.ReadFile(s.cfg.OllamaOutputFile)
+    defer logOutput.Close()
+
+    scanner, err := scan.NewScanner(cfg) // 初始化通用扫描器
     if err != nil {
-        return fmt.Errorf("读取服务检测结果失败: %w", err)
+        slog.Error("初始化失败", "error", err)
+        logOutput.Close()
+        os.Exit(1)
     }
-    lines := strings.Split(string(data), "\n")
-    
-    // 计算有效记录数（排除表头和空行）
-    var validRecords int
-    for _, line := range lines {
-        if strings.TrimSpace(line) != "" && !strings.HasPrefix(line, "IP地址") {
-            validRecords++
-        }
+    if *tui {
+        scanner.EnableDashboard()
     }
-    
-    s.progress = pb.New(validRecords) // 使用实际有效记录数
-    s.progress.SetTemplateString(`{{ "测试进度:" }} {{counters . }} {{ bar . "[" "=" ">" "." "]" }} {{percent . }}`)
-    s.progress.Start()
-
-    // 创建新的reader
-    reader := csv.NewReader(bytes.NewReader(data))
-    reader.Read() // 跳过表头
-
-    workerPool := make(chan struct{}, s.cfg.MaxWorkers)
-    var wg sync.WaitGroup
-    var writeMu sync.Mutex
 
-    for {
-        record, err := reader.Read()
+    // 非交互模式：执行指定阶段，失败时以非零状态退出
+    if *mode != "" {
+        err := runMode(scanner, *mode)
         if err != nil {
-            break
+            slog.Error("阶段执行失败", "mode", *mode, "error", err)
         }
-        
-        if len(record) < 3 {
-            fmt.Printf("⚠️ 无效记录: %v\n", record)
-            continue
+        scanner.Close()
+        if err != nil {
+            logOutput.Close()
+            os.Exit(1)
         }
-        ip := record[0]
-        modelName := record[2]
-        
-        workerPool <- struct{}{}
-        wg.Add(1)
-        
-        go func(ip, modelName string) {
-            defer func() {
-                <-workerPool
-                wg.Done()
-                s.progress.Increment()
-            }()
-            if net.ParseIP(ip) == nil || modelName == "" {
-                return
-            }
-
-            start := time.Now()
-            payload := map[string]interface{}{
-                "model":  modelName,
-                "prompt": s.cfg.BenchPrompt,
-                "stream": true,
-            }
-
-            body, _ := json.Marshal(payload)
-            req, _ := http.NewRequest("POST", 
-                fmt.Sprintf("http://%s:%d/api/generate", ip, s.cfg.Port),
-                bytes.NewReader(body))
-
-            client := &http.Client{Timeout: s.cfg.BenchTimeout}
-            resp, err := client.Do(req)
-            if err != nil {
-                writeMu.Lock()
-                defer writeMu.Unlock()
-                
-                s.csvWriter.Write([]string{
-                    ip,
-                    strconv.Itoa(s.cfg.Port),
-                    modelName,
-                    "连接失败",
-                    "0",
-                    "0",
-                })
-                return
-            }
-
-            if resp.StatusCode != http.StatusOK {
-                writeMu.Lock()
-                defer writeMu.Unlock()
-                
-                s.csvWriter.Write([]string{
-                    ip,
-                    strconv.Itoa(s.cfg.Port),
-                    modelName,
-                    fmt.Sprintf("HTTP %d", resp.StatusCode),
-                    "0",
-                    "0",
-                })
-                resp.Body.Close()
-                return
-            }
-            
-            scanner := bufio.NewScanner(resp.Body)
-            var (
-                firstToken time.Time
-                lastToken  time.Time
-                tokenCount int
-            )
-
-            for scanner.Scan() {
-                if tokenCount == 0 {
-                    firstToken = time.Now()
-                }
-                lastToken = time.Now()
-                tokenCount++
-
-                var data map[string]interface{}
-                if err := json.Unmarshal(scanner.Bytes(), &data); err != nil {
-                    continue
-                }
-
-                if done, _ := data["done"].(bool); done {
-                    break
-                }
-            }
-            resp.Body.Close()
-
-            if tokenCount == 0 {
-                writeMu.Lock()
-                defer writeMu.Unlock()
-                
-                s.csvWriter.Write([]string{
-                    ip,
-                    strconv.Itoa(s.cfg.Port),
-                    modelName,
-                    "无响应",
-                    "0",
-                    "0",
-                })
-                return
-            }
-
-            totalTime := lastToken.Sub(start)
-            latency := firstToken.Sub(start)
-            tps := float64(tokenCount) / totalTime.Seconds()
-
-            writeMu.Lock()
-            defer writeMu.Unlock()
-            
-            s.csvWriter.Write([]string{
-                ip,
-                strconv.Itoa(s.cfg.Port),
-                modelName,
-                "成功",
-                strconv.FormatInt(latency.Milliseconds(), 10),
-                fmt.Sprintf("%.2f", tps),
-            })
-            // 打印成功测试结果
-            fmt.Printf("✅ 成功测试: %s %s %dms %f\n", 
-                ip, 
-                modelName,
-                latency.Milliseconds(),
-                tps)
-            s.csvWriter.Flush()
-        }(ip, modelName)
-    }
-    
-    wg.Wait()
-    s.progress.Finish()
-    return nil
-}
-
-// 主函数
-func main() {
-    scanner, err := NewScanner() // 初始化通用扫描器
-    if err != nil {
-        fmt.Printf("初始化失败: %v\n", err)
         return
     }
     defer scanner.Close()
@@ -439,65 +101,106 @@ func main() {
         fmt.Println("1. 端口扫描")
         fmt.Println("2. 服务检测")
         fmt.Println("3. 性能测试")
+        fmt.Println("4. 边扫描边检测")
+        fmt.Println("5. 并行执行扫描任务")
+        fmt.Println("6. 嵌入性能测试")
         fmt.Println("0. 退出程序")
         
         var choice int
-        fmt.Print("请输入选项(0-3): ")
+        fmt.Print("请输入选项(0-6): ")
         fmt.Scan(&choice)
         
+        var stage func(context.Context) error
         switch choice {
         case 1:
-            if err := scanner.ScanIPs(); err != nil {
-                continue
-            }
+            stage = scanner.ScanIPs
         case 2:
-            if err := scanner.DetectOllama(); err != nil {
-                continue
-            }
+            stage = scanner.DetectOllama
         case 3:
-            if err := scanner.BenchmarkOllama(); err != nil {
-                continue
-            }
+            stage = scanner.BenchmarkOllama
+        case 4:
+            stage = scanner.ScanAndDetect
+        case 5:
+            stage = scanner.RunJobs
+        case 6:
+            stage = scanner.BenchmarkEmbeddings
         case 0:
             fmt.Println("👋 再见!")
             return
             
         default:
             fmt.Println("❌ 无效的选项，请重新选择")
+            continue
+        }
+
+        if err := scanner.RunStage(stage); err != nil {
+            slog.Error("阶段执行失败", "error", err)
         }
     }
 }
 
-// 配置初始化
-func init() {
-    // 设置配置文件名
-    viper.SetConfigName("config")
-    viper.SetConfigType("yaml")
-    viper.AddConfigPath(".")
-    
-    // 设置zmap 默认值
-    viper.SetDefault("port", 11434)
-    viper.SetDefault("inputFile", "ips.txt")
-    viper.SetDefault("outputFile", "results.csv") 
-    viper.SetDefault("rate", 10000)
-    viper.SetDefault("bandwidth", "100M")
-    
-    // 设置ollama默认值
-    viper.SetDefault("maxWorkers", 100)
-    viper.SetDefault("maxIdleConns", 100)
-    viper.SetDefault("timeout", "5s")
-    viper.SetDefault("idleConnTimeout", "90s")
-    
-    // 设置ollama性能测试默认值
-    viper.SetDefault("benchTimeout", "30s")
-    viper.SetDefault("benchPrompt", "用一句话自我介绍")
+// 按 -mode 参数执行阶段
+func runMode(s *scan.Scanner, mode string) error {
+    var stage func(context.Context) error
+    switch mode {
+    case "scan":
+        stage = s.ScanIPs
+    case "detect":
+        stage = s.DetectOllama
+    case "scan-detect":
+        stage = s.ScanAndDetect
+    case "bench":
+        stage = s.BenchmarkOllama
+    case "embed":
+        stage = s.BenchmarkEmbeddings
+    case "all":
+        stage = s.ScanAll
+    default:
+        return fmt.Errorf("未知的运行模式: %s（可选 scan、detect、scan-detect、bench、embed、all、check）", mode)
+    }
+    return s.RunStage(stage)
+}
 
-    // 设置中间文件默认值
-    viper.SetDefault("scanOutputFile", "ip.csv")
-    viper.SetDefault("ollamaOutputFile", "ollama.csv") 
+// 未注入的构建信息显示为 unknown
+func orUnknown(value string) string {
+    if value == "" {
+        return "unknown"
+    }
+    return value
+}
+
+// 打印运行前检查报告，全部通过时返回 true
+func runCheck(cfg *scan.Config, loadErr error) bool {
+    fmt.Println("运行前检查:")
+    if loadErr != nil {
+        fmt.Printf("❌ 配置解析: %v\n", loadErr)
+        return false
+    }
+    passed := true
+    for _, result := range scan.Preflight(context.Background(), cfg) {
+        if result.Err != nil {
+            passed = false
+            fmt.Printf("❌ %s: %v\n", result.Name, result.Err)
+            continue
+        }
+        fmt.Printf("✅ %s: %s\n", result.Name, result.Detail)
+    }
+    if passed {
+        fmt.Println("全部检查通过")
+    } else {
+        fmt.Println("存在未通过的检查项，请修复后再运行")
+    }
+    return passed
+}
 
-    // 读取配置文件
-    if err := viper.ReadInConfig(); err != nil {
-        fmt.Printf("⚠️ 配置文件读取失败: %v\n", err)
+// merge 子命令：合并多个探测点的性能测试结果，同一组合在各探测点的延迟和速度并列输出
+func runMerge(args []string) error {
+    fs := flag.NewFlagSet("merge", flag.ExitOnError)
+    output := fs.String("o", "merged.csv", "合并结果输出文件")
+    lang := fs.String("lang", "zh", "合并结果的表头语言: zh、en")
+    fs.Parse(args)
+    if fs.NArg() == 0 {
+        return errors.New("用法: scan merge [-o merged.csv] [-lang zh] <结果文件...>")
     }
+    return scan.Merge(*output, *lang, fs.Args())
 }
@@ -0,0 +1,61 @@
+package scan
+
+import (
+    "bufio"
+    "errors"
+    "fmt"
+    "net/http"
+    "os"
+    "strings"
+)
+
+// 读取按主机区分的令牌文件，每行“主机 令牌”，主机为IP或IP:端口，#开头为注释
+func loadAuthTokens(path string) (map[string]string, error) {
+    tokens := make(map[string]string)
+    if path == "" {
+        return tokens, nil
+    }
+    file, err := os.Open(path)
+    if err != nil {
+        return nil, err
+    }
+    defer file.Close()
+
+    scanner := bufio.NewScanner(file)
+    for lineNo := 1; scanner.Scan(); lineNo++ {
+        line := strings.TrimSpace(scanner.Text())
+        if line == "" || strings.HasPrefix(line, "#") {
+            continue
+        }
+        fields := strings.Fields(line)
+        if len(fields) != 2 {
+            return nil, fmt.Errorf("第%d行格式错误，应为“主机 令牌”", lineNo)
+        }
+        tokens[fields[0]] = fields[1]
+    }
+    return tokens, scanner.Err()
+}
+
+// 查找目标使用的令牌，优先匹配IP:端口，其次IP，最后使用全局令牌
+func (s *Scanner) authToken(ip string, port int) string {
+    if token, ok := s.authTokens[hostPort(ip, port)]; ok {
+        return token
+    }
+    if token, ok := s.authTokens[ip]; ok {
+        return token
+    }
+    return s.cfg.AuthToken
+}
+
+// 按目标主机附加认证头，由调用方传入目标：设置 baseURL 后请求地址不再是目标本身
+func (s *Scanner) authorize(req *http.Request, ip string, port int) {
+    if token := s.authToken(ip, port); token != "" {
+        req.Header.Set("Authorization", "Bearer "+token)
+    }
+}
+
+// 判断是否为携带令牌后仍被拒绝的请求，与未部署服务的404区分
+func (s *Scanner) authRejected(ip string, port int, err error) bool {
+    var se *statusError
+    return errors.As(err, &se) && se.code == http.StatusUnauthorized && s.authToken(ip, port) != ""
+}
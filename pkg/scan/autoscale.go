@@ -0,0 +1,137 @@
+package scan
+
+import (
+    "fmt"
+    "log/slog"
+    "runtime"
+    "sync"
+    "time"
+)
+
+// 并发自动扩缩容，定期根据CPU、内存、协程数、文件描述符和超时率调整工作池上限
+// 资源紧张或超时率过高时按比例收缩，名额不足且资源宽裕时逐步扩张
+type autoscaler struct {
+    s        *Scanner
+    stage    string
+    pool     *workerPool
+    mu       sync.Mutex
+    attempts int
+    errors   int
+    lastCPU  time.Duration
+    lastTick time.Time
+    stop     chan struct{}
+    done     chan struct{}
+}
+
+// 启动扩缩容控制器，未开启 autoscale 时返回nil
+func (s *Scanner) startAutoscaler(stage string, pool *workerPool) *autoscaler {
+    if !s.cfg.Autoscale {
+        return nil
+    }
+    a := &autoscaler{
+        s:        s,
+        stage:    stage,
+        pool:     pool,
+        lastCPU:  cpuTime(),
+        lastTick: time.Now(),
+        stop:     make(chan struct{}),
+        done:     make(chan struct{}),
+    }
+    go a.loop()
+    return a
+}
+
+// 初始并发数，开启扩缩容时限制在上下界之内
+func (s *Scanner) initialWorkers() int {
+    workers := s.cfg.MaxWorkers
+    if s.cfg.Autoscale {
+        workers = min(max(workers, s.cfg.AutoscaleMin), s.cfg.AutoscaleMax)
+    }
+    return workers
+}
+
+// 记录一次请求结果，failed 表示超时等说明负载过高的失败
+func (a *autoscaler) observe(failed bool) {
+    if a == nil {
+        return
+    }
+    a.mu.Lock()
+    defer a.mu.Unlock()
+    a.attempts++
+    if failed {
+        a.errors++
+    }
+}
+
+// 停止控制器
+func (a *autoscaler) close() {
+    if a == nil {
+        return
+    }
+    close(a.stop)
+    <-a.done
+}
+
+func (a *autoscaler) loop() {
+    defer close(a.done)
+    ticker := time.NewTicker(a.s.cfg.AutoscaleInterval)
+    defer ticker.Stop()
+    for {
+        select {
+        case <-ticker.C:
+            a.adjust()
+        case <-a.stop:
+            return
+        }
+    }
+}
+
+// 根据本周期的指标调整一次上限
+func (a *autoscaler) adjust() {
+    cfg := a.s.cfg
+    limit, saturated := a.pool.saturation()
+
+    a.mu.Lock()
+    attempts, errors := a.attempts, a.errors
+    a.attempts, a.errors = 0, 0
+    a.mu.Unlock()
+
+    // 本周期进程CPU占用，按全部核心计算
+    now := time.Now()
+    cpu := cpuTime()
+    var cpuLoad float64
+    if wall := now.Sub(a.lastTick); wall > 0 && cpu > 0 {
+        cpuLoad = float64(cpu-a.lastCPU) / float64(wall) / float64(runtime.NumCPU())
+    }
+    a.lastCPU, a.lastTick = cpu, now
+
+    var mem runtime.MemStats
+    runtime.ReadMemStats(&mem)
+    goroutines := runtime.NumGoroutine()
+
+    var reason string
+    switch {
+    case cfg.AutoscaleMaxCPU > 0 && cpuLoad > cfg.AutoscaleMaxCPU:
+        reason = fmt.Sprintf("CPU %.0f%%", cpuLoad*100)
+    case cfg.AutoscaleMaxMemoryMB > 0 && mem.HeapAlloc > uint64(cfg.AutoscaleMaxMemoryMB)<<20:
+        reason = fmt.Sprintf("内存 %dMB", mem.HeapAlloc>>20)
+    case cfg.AutoscaleMaxGoroutines > 0 && goroutines > cfg.AutoscaleMaxGoroutines:
+        reason = fmt.Sprintf("协程 %d", goroutines)
+    case openFDs() > fdLimit()*9/10:
+        reason = fmt.Sprintf("文件描述符 %d", openFDs())
+    case attempts > 0 && float64(errors)/float64(attempts) > cfg.AutoscaleMaxErrorRate:
+        reason = fmt.Sprintf("超时率 %.0f%%", float64(errors)*100/float64(attempts))
+    }
+
+    next := limit
+    if reason != "" {
+        next = max(limit*3/4, cfg.AutoscaleMin)
+    } else if saturated {
+        next = min(limit+max(limit/10, 1), cfg.AutoscaleMax)
+    }
+    if next == limit {
+        return
+    }
+    a.pool.setLimit(next)
+    slog.Info("调整并发数", "stage", a.stage, "workers", next, "reason", reason)
+}
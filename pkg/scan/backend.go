@@ -0,0 +1,145 @@
+package scan
+
+import (
+    "bufio"
+    "context"
+    "fmt"
+    "log/slog"
+    "os"
+    "os/exec"
+    "strconv"
+    "strings"
+    "time"
+)
+
+// 支持的扫描程序
+const (
+    scannerZmap    = "zmap"
+    scannerMasscan = "masscan"
+    scannerNative  = "native"
+)
+
+// 校验扫描程序配置
+func validateScanner(name string) error {
+    switch name {
+    case scannerZmap, scannerMasscan, scannerNative:
+        return nil
+    default:
+        return fmt.Errorf("未知的扫描程序: %s（可选 zmap、masscan、native）", name)
+    }
+}
+
+// 确认扫描程序已安装，原生扫描不依赖外部程序
+func (s *Scanner) checkScanner() error {
+    if s.cfg.Scanner == scannerNative {
+        return nil
+    }
+    if _, err := exec.LookPath(s.cfg.Scanner); err != nil {
+        return fmt.Errorf("未找到扫描程序 %s，请确认已安装并加入PATH: %w", s.cfg.Scanner, err)
+    }
+    return nil
+}
+
+// 按配置的扫描程序构建命令，output 为 "-" 时结果输出到标准输出
+// ctx 取消时向扫描程序发送中断信号，让它写完已有结果后退出
+func (s *Scanner) scanCommand(ctx context.Context, input, output string) *exec.Cmd {
+    var cmd *exec.Cmd
+    if s.cfg.Scanner == scannerMasscan {
+        cmd = s.masscanCommand(ctx, input, output)
+    } else {
+        cmd = s.zmapCommand(ctx, input, output)
+    }
+    cmd.Cancel = func() error {
+        return cmd.Process.Signal(os.Interrupt)
+    }
+    cmd.WaitDelay = s.cfg.DrainTimeout
+    return cmd
+}
+
+// 构建 masscan 命令，结果使用 -oL 列表格式输出
+// masscan 没有带宽限制参数，按带宽折算的发包速率与 rate 取较小值
+func (s *Scanner) masscanCommand(ctx context.Context, input, output string) *exec.Cmd {
+    rate, bandwidth := s.scheduledRate(time.Now())
+    if limit := bandwidthRate(bandwidth); limit > 0 && limit < rate {
+        rate = limit
+    }
+    if output == "-" {
+        output = "/dev/stdout"
+    }
+    cmd := exec.CommandContext(ctx, "sudo", "masscan",
+        "-iL", input,
+        "-oL", output,
+        "-p", s.portSpec(),
+        "--rate", strconv.Itoa(rate),
+    )
+
+    // 打印完整命令
+    slog.Info("执行命令", "command", strings.Join(cmd.Args, " "))
+    return cmd
+}
+
+// 按带宽折算每秒发包数，按每个探测包占用84字节线路带宽计算，无法解析时返回0
+func bandwidthRate(bandwidth string) int {
+    bandwidth = strings.ToUpper(strings.TrimSpace(bandwidth))
+    multiplier := 1.0
+    switch {
+    case strings.HasSuffix(bandwidth, "G"):
+        multiplier = 1e9
+    case strings.HasSuffix(bandwidth, "M"):
+        multiplier = 1e6
+    case strings.HasSuffix(bandwidth, "K"):
+        multiplier = 1e3
+    }
+    value, err := strconv.ParseFloat(strings.TrimRight(bandwidth, "GMK"), 64)
+    if err != nil || value <= 0 {
+        return 0
+    }
+    return max(int(value*multiplier/(84*8)), 1)
+}
+
+// 把扫描程序输出的一行转换为检测使用的目标格式（IP，多端口时为 "IP,端口"），不是结果行时返回false
+func (s *Scanner) scanLine(line string) (string, bool) {
+    line = strings.TrimSpace(line)
+    if s.cfg.Scanner != scannerMasscan {
+        return line, line != ""
+    }
+    // masscan 列表格式: open tcp 11434 1.2.3.4 1700000000，注释行以#开头
+    fields := strings.Fields(line)
+    if len(fields) < 4 || fields[0] != "open" {
+        return "", false
+    }
+    if len(s.ports) > 1 {
+        return fields[3] + "," + fields[2], true
+    }
+    return fields[3], true
+}
+
+// 把扫描程序的原始输出转换为目标列表写入 output
+func (s *Scanner) normalizeScanOutput(raw, output string) error {
+    in, err := os.Open(raw)
+    if err != nil {
+        return err
+    }
+    defer in.Close()
+    out, err := createOutput(output, s.fileMode)
+    if err != nil {
+        return err
+    }
+
+    writer := bufio.NewWriter(out)
+    reader := bufio.NewScanner(in)
+    for reader.Scan() {
+        if target, ok := s.scanLine(reader.Text()); ok {
+            fmt.Fprintln(writer, target)
+        }
+    }
+    if err := reader.Err(); err != nil {
+        out.Close()
+        return err
+    }
+    if err := writer.Flush(); err != nil {
+        out.Close()
+        return err
+    }
+    return out.Close()
+}
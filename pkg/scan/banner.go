@@ -0,0 +1,41 @@
+package scan
+
+import (
+    "context"
+    "fmt"
+    "io"
+    "strconv"
+    "strings"
+    "time"
+)
+
+// 抓取TCP横幅，用于识别开放端口上实际运行的服务
+// 先等待服务端主动发送，没有数据时再发送最简单的HTTP请求读取响应开头，返回连接是否建立
+func (s *Scanner) grabBanner(ctx context.Context, ip string, port int) (string, bool) {
+    if err := s.inflight.acquire(ctx); err != nil {
+        return "", false
+    }
+    defer s.inflight.release()
+
+    conn, err := s.dialer(s.cfg.BannerTimeout).DialContext(ctx, hostPort(ip, port))
+    if err != nil {
+        return "", false
+    }
+    defer conn.Close()
+
+    buf := make([]byte, s.cfg.BannerBytes)
+    conn.SetReadDeadline(time.Now().Add(s.cfg.BannerTimeout / 2))
+    n, _ := conn.Read(buf)
+    if n == 0 {
+        conn.SetDeadline(time.Now().Add(s.cfg.BannerTimeout))
+        fmt.Fprintf(conn, "GET / HTTP/1.0\r\nHost: %s\r\n\r\n", ip)
+        n, _ = io.ReadFull(conn, buf)
+    }
+    return sanitizeBanner(buf[:n]), true
+}
+
+// 转义不可打印字符，保证横幅可以安全写入CSV
+func sanitizeBanner(data []byte) string {
+    quoted := strconv.QuoteToASCII(string(data))
+    return strings.TrimSuffix(strings.TrimPrefix(quoted, `"`), `"`)
+}
@@ -0,0 +1,643 @@
+package scan
+
+import (
+    "bufio"
+    "context"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "log/slog"
+    "net"
+    "net/http"
+    "os"
+    "sync"
+    "time"
+
+    "go.opentelemetry.io/otel/attribute"
+    "go.opentelemetry.io/otel/trace"
+)
+
+// 性能测试目标
+type benchTarget struct {
+    ip      string
+    port    int
+    model   string
+    service string // 检测到的服务类型，测试清单中的目标为空，按 Ollama 处理
+}
+
+// 目标能否用配置的接口测试：Ollama 支持全部接口，vLLM 和其他 OpenAI 兼容服务只支持 openai 接口
+func (s *Scanner) benchable(service string) bool {
+    switch service {
+    case "", serviceOllama:
+        return true
+    case serviceVLLM, serviceOpenAI:
+        return s.cfg.BenchEndpoint == benchEndpointOpenAI
+    default:
+        return false
+    }
+}
+
+// 一次性能测试运行，负责结果输出、断点保存和并发调度
+// 既可以由 BenchmarkOllama 读取检测结果驱动，也可以由检测阶段以流水线方式驱动
+type benchRun struct {
+    s              *Scanner
+    ctx            context.Context
+    file           *partialFile
+    sharedFile     *partialFile // 开启脱敏时的脱敏副本，未开启时为nil
+    writer         resultWriter
+    writeMu        sync.Mutex
+    cp             *checkpoint
+    manifest       *stageManifest
+    progress       *progress
+    workerPool     *workerPool
+    scaler         *autoscaler
+    wg             sync.WaitGroup
+    stopCheckpoint chan struct{}
+    checkpointDone chan struct{}
+    failures       *failureStats
+    summary        *stageSummary
+    drain          *drainer
+    warmPool       chan struct{}
+    prewarmed      bool // 已在计时阶段前统一预热
+    prior          map[string]float64 // 历史结果中各组合的生成速度
+    priorFailures  map[string]bool    // 历史结果中确定性失败的组合
+    precounted     bool               // 断点中已完成的组合已计入进度
+    baseline       map[string]float64 // 基线结果中各组合的生成速度
+}
+
+// 准备性能测试：加载断点、打开结果文件并启动断点落盘，input 为断点对应的输入文件
+func (s *Scanner) newBenchRun(ctx context.Context, manifest *stageManifest, input string) (*benchRun, error) {
+    drain := newDrainer(ctx, s.cfg.DrainTimeout)
+    run := &benchRun{
+        s:              s,
+        ctx:            drain.requests,
+        drain:          drain,
+        manifest:       manifest,
+        workerPool:     newWorkerPool(s.initialWorkers(), drain.dispatch.Done()),
+        warmPool:       make(chan struct{}, max(s.cfg.WarmupWorkers, 1)),
+        stopCheckpoint: make(chan struct{}),
+        checkpointDone: make(chan struct{}),
+        failures:       newFailureStats(),
+        summary:        newStageSummary("benchmark"),
+    }
+
+    // 只在续测时记录断点，否则每次都从头测试
+    cp, err := s.loadBenchCheckpoint(input)
+    if err != nil {
+        drain.stop()
+        return nil, fmt.Errorf("读取断点文件失败: %w", err)
+    }
+    run.cp = cp
+
+    // 加载基线结果，写入时计算相对基线的变化
+    if s.cfg.BaselineFile != "" {
+        baseline, err := loadPriorResults(s.cfg.BaselineFile, s.cfg.OutputFormat)
+        if err != nil {
+            drain.stop()
+            return nil, fmt.Errorf("读取基线结果失败: %w", err)
+        }
+        run.baseline = baseline
+        slog.Info("已加载基线结果", "count", len(baseline))
+    }
+
+    // 加载历史结果，须在结果文件被截断前读取
+    if s.cfg.PriorResultsFile != "" && s.cfg.SkipIfFasterThan > 0 {
+        prior, err := loadPriorResults(s.cfg.PriorResultsFile, s.cfg.OutputFormat)
+        if err != nil {
+            drain.stop()
+            return nil, fmt.Errorf("读取历史测试结果失败: %w", err)
+        }
+        run.prior = prior
+        slog.Info("已加载历史测试结果", "count", len(prior))
+    }
+
+    // 加载历史失败记录，同样须在结果文件被截断前读取
+    if s.cfg.SkipPreviousFailures {
+        failures, err := loadPriorFailures(s.cfg.previousResultsFile(), s.cfg.OutputFormat)
+        // 首次运行时历史文件还不存在，不跳过任何组合
+        if errors.Is(err, os.ErrNotExist) {
+            failures, err = map[string]bool{}, nil
+        }
+        if err != nil {
+            drain.stop()
+            return nil, fmt.Errorf("读取历史失败记录失败: %w", err)
+        }
+        run.priorFailures = failures
+        slog.Info("已加载历史失败记录", "count", len(failures))
+    }
+
+    // 续测时追加写入已有结果，否则创建新文件，测试完成前写入 .partial 文件
+    file, err := openPartial(s.cfg.OutputFile, s.cfg.Resume, s.fileMode)
+    if err != nil {
+        drain.stop()
+        return nil, fmt.Errorf("创建CSV文件失败: %w", err)
+    }
+    run.file = file
+
+    // 空文件才写入表头
+    run.writer, err = s.newResultWriter(file, benchHeader(s.cfg))
+    if err != nil {
+        file.close(false)
+        drain.stop()
+        return nil, fmt.Errorf("写入测试表头失败: %w", err)
+    }
+    run.writer, run.sharedFile, err = s.withRedactedCopy(run.writer, s.cfg.OutputFile, s.cfg.Resume, benchHeader(s.cfg))
+    if err != nil {
+        file.close(false)
+        drain.stop()
+        return nil, err
+    }
+
+    go run.checkpointLoop()
+    run.scaler = s.startAutoscaler("性能测试", run.workerPool)
+    return run, nil
+}
+
+// 定期落盘断点，未开启续测时不记录
+func (r *benchRun) checkpointLoop() {
+    defer close(r.checkpointDone)
+    if r.cp == nil {
+        return
+    }
+    ticker := time.NewTicker(r.s.cfg.CheckpointInterval)
+    defer ticker.Stop()
+    for {
+        select {
+        case <-ticker.C:
+            r.saveCheckpoint()
+        case <-r.stopCheckpoint:
+            return
+        }
+    }
+}
+
+// 先刷新结果再写断点，保证断点中的组合都已写入结果文件
+func (r *benchRun) saveCheckpoint() {
+    r.writeMu.Lock()
+    defer r.writeMu.Unlock()
+    r.writer.flush()
+    if err := r.cp.save(); err != nil {
+        slog.Warn("写入断点文件失败", "error", err)
+    }
+}
+
+// 写入一条测试结果
+func (r *benchRun) write(result BenchResult) {
+    r.scaler.observe(result.Reason == "超时")
+    // 基线按真实IP匹配，须在脱敏前计算
+    if base := r.baseline[checkpointKey(hostPort(result.IP, result.Port), result.Model)]; base > 0 && result.Status == "成功" && result.TokensPerSec > 0 {
+        delta := (result.TokensPerSec - base) / base
+        result.BaselineDelta = &delta
+        if delta < 0 {
+            r.manifest.add("regressed", 1)
+        }
+    }
+
+    r.writeMu.Lock()
+    defer r.writeMu.Unlock()
+    // 结果文件和数据库保留真实IP，供基线对比、跳过历史失败和关联检测结果，只对脱敏副本和推送的结果脱敏
+    r.s.store.recordBench(result)
+    r.s.dash.benchmarked(result)
+    r.writer.write(result)
+    r.writer.flush()
+    result.IP = r.s.redactIP(result.IP)
+    r.s.sinks.publish("benchmark", result.IP, result)
+    r.s.metrics.bench(result)
+    r.summary.bench(result)
+    if result.Status == "成功" {
+        r.manifest.add("success", 1)
+    } else {
+        r.manifest.add("failed", 1)
+        r.failures.add(result.Reason)
+    }
+}
+
+// 统计断点中已完成的目标数，统计后跳过这些目标时不再推进进度
+func (r *benchRun) resumedCount(targets []benchTarget) int {
+    r.precounted = true
+    var done int
+    for _, t := range targets {
+        if r.cp.has(checkpointKey(hostPort(t.ip, t.port), t.model)) {
+            done++
+        }
+    }
+    return done
+}
+
+// 推进进度条，流水线模式下没有进度条
+func (r *benchRun) increment() {
+    r.s.health.touch()
+    r.progress.increment()
+}
+
+// 提交一个测试目标，工作池满时阻塞，收到退出信号后不再派发
+func (r *benchRun) submit(ip string, port int, modelName string) {
+    key := checkpointKey(hostPort(ip, port), modelName)
+    // 跳过断点中已完成的组合
+    if r.cp.has(key) {
+        if !r.precounted {
+            r.increment()
+        }
+        r.manifest.add("resumed", 1)
+        return
+    }
+    // 跳过历史上已足够快的组合
+    if r.prior != nil && r.prior[key] > r.s.cfg.SkipIfFasterThan {
+        r.increment()
+        r.manifest.add("skipped_fast", 1)
+        return
+    }
+    // 跳过上次确定性失败的组合，跳过的组合不写入本次结果，下一轮会重新测试一次
+    if r.priorFailures[key] {
+        r.increment()
+        r.manifest.add("skipped_failed", 1)
+        return
+    }
+
+    if !r.workerPool.acquire(r.drain.dispatch.Done()) {
+        return
+    }
+    r.wg.Add(1)
+
+    go func() {
+        defer func() {
+            r.workerPool.release()
+            r.wg.Done()
+            r.increment()
+        }()
+        if net.ParseIP(ip) == nil || modelName == "" {
+            return
+        }
+        // 流水线模式下目标逐个到达，测试前单独预热
+        if r.s.cfg.Warmup && !r.prewarmed {
+            r.warm(ip, port, modelName)
+        }
+
+        if len(r.s.cfg.PromptLengths) == 0 {
+            result := r.measure(ip, port, modelName, r.s.promptFor(modelName))
+            // 请求被强制取消时不记录结果，续测时重新测试
+            if r.ctx.Err() != nil {
+                return
+            }
+            r.write(result)
+        } else {
+            // 依次测试各长度档位，观察吞吐随上下文长度的变化
+            for _, length := range r.s.cfg.PromptLengths {
+                result := r.measure(ip, port, modelName, length.prompt())
+                if r.ctx.Err() != nil {
+                    return
+                }
+                result.PromptLength = length.Name
+                r.write(result)
+            }
+        }
+        r.cp.mark(key)
+    }()
+}
+
+// 先测单流（按配置重复多次取分位数），成功后按配置追加多路测试
+func (r *benchRun) measure(ip string, port int, modelName, prompt string) BenchResult {
+    var result BenchResult
+    if r.s.cfg.BenchRepeat > 1 {
+        result = r.s.benchmarkRepeated(r.ctx, ip, port, modelName, prompt)
+    } else {
+        result = r.s.benchmarkModel(r.ctx, ip, port, modelName, prompt)
+    }
+    if result.Status == "成功" && r.s.cfg.multiStream() {
+        r.s.benchmarkStreams(r.ctx, &result, prompt)
+    }
+    return result
+}
+
+// 等待所有测试完成，保存断点并关闭结果文件
+func (r *benchRun) finish() error {
+    r.drain.wait(&r.wg)
+    r.scaler.close()
+    defer r.drain.stop()
+    close(r.stopCheckpoint)
+    <-r.checkpointDone
+    // 先刷新结果，中断时保存的断点中的组合都已写入结果文件
+    r.writeMu.Lock()
+    r.writer.flush()
+    if err := r.cp.finish(r.drain.interrupted.Load()); err != nil {
+        slog.Warn("更新断点文件失败", "error", err)
+    }
+    r.writeMu.Unlock()
+    r.progress.finish()
+    r.s.reportFailures("benchmark", r.failures)
+    r.s.reportSummary(r.summary, r.failures)
+
+    r.writer.flush()
+    if err := r.file.close(!r.drain.interrupted.Load()); err != nil {
+        return fmt.Errorf("关闭CSV文件失败: %w", err)
+    }
+    if r.sharedFile != nil {
+        if err := r.sharedFile.close(!r.drain.interrupted.Load()); err != nil {
+            return fmt.Errorf("关闭脱敏结果文件失败: %w", err)
+        }
+    }
+    if r.s.cfg.EfficiencyFile != "" {
+        if err := r.s.writeEfficiency(r.file.location()); err != nil {
+            slog.Warn("生成效率排名失败", "error", err)
+        }
+    }
+    if r.drain.interrupted.Load() {
+        return errInterrupted
+    }
+    return nil
+}
+
+// 性能测试使用的接口
+const (
+    benchEndpointGenerate = "generate"
+    benchEndpointChat     = "chat"
+    benchEndpointOpenAI   = "openai" // OpenAI 兼容的 /v1/chat/completions，响应为 SSE
+)
+
+// 校验性能测试接口配置
+func validateBenchEndpoint(endpoint string) error {
+    switch endpoint {
+    case benchEndpointGenerate, benchEndpointChat, benchEndpointOpenAI:
+        return nil
+    default:
+        return fmt.Errorf("未知的性能测试接口: %s（可选 generate、chat、openai）", endpoint)
+    }
+}
+
+// 按配置的接口生成请求路径和请求体，chat 和 openai 接口以单条用户消息发送提示词
+func (s *Scanner) benchRequest(modelName, prompt string) (string, map[string]interface{}) {
+    payload := map[string]interface{}{
+        "model":  modelName,
+        "stream": true,
+    }
+    switch s.cfg.BenchEndpoint {
+    case benchEndpointChat:
+        payload["messages"] = []map[string]string{{"role": "user", "content": prompt}}
+        return "/api/chat", payload
+    case benchEndpointOpenAI:
+        payload["messages"] = []map[string]string{{"role": "user", "content": prompt}}
+        // 要求在最后一块附带Token统计，不支持的服务会忽略
+        payload["stream_options"] = map[string]bool{"include_usage": true}
+        return "/v1/chat/completions", payload
+    }
+    payload["prompt"] = prompt
+    return "/api/generate", payload
+}
+
+// 取出流式响应帧中生成的文本，generate 接口在 response 字段，chat 接口在 message.content 字段
+func responseText(data map[string]interface{}) string {
+    if text, ok := data["response"].(string); ok {
+        return text
+    }
+    message, _ := data["message"].(map[string]interface{})
+    text, _ := message["content"].(string)
+    return text
+}
+
+// 性能测试在 benchConnectTimeout 内未收到响应头
+var errConnectTimeout = errors.New("连接或等待响应头超时")
+
+// 使用指定提示词对单个模型进行性能测试
+func (s *Scanner) benchmarkModel(ctx context.Context, ip string, port int, modelName, prompt string) (result BenchResult) {
+    result = BenchResult{IP: ip, Port: port, Model: modelName, ProbeLabel: s.cfg.ProbeLabel}
+
+    _, span := s.tracer.Start(ctx, "benchmark.model",
+        trace.WithAttributes(
+            attribute.String("ip", ip),
+            attribute.String("model", modelName),
+        ))
+    defer func() {
+        span.SetAttributes(
+            attribute.String("status", result.Status),
+            attribute.Float64("tps", result.TokensPerSec),
+        )
+        span.End()
+    }()
+
+    start := time.Now()
+    path, payload := s.benchRequest(modelName, prompt)
+    openai := s.cfg.BenchEndpoint == benchEndpointOpenAI
+    if s.cfg.QuickBench {
+        // 快速模式只需要首Token，限制生成长度
+        if openai {
+            payload["max_tokens"] = 1
+        } else {
+            payload["options"] = map[string]interface{}{"num_predict": 1}
+        }
+    }
+
+    req, err := s.newJSONRequest(ctx, ip, port, path, payload)
+    if err != nil {
+        result.Status = "请求构建失败"
+        result.Reason = classifyError(err)
+        return result
+    }
+
+    if err := s.throttle.acquire(ctx, ip); err != nil {
+        result.Status = "连接失败"
+        result.Reason = classifyError(err)
+        return result
+    }
+    defer func() { s.throttle.release(ip, result.TokensPerSec) }()
+
+    if err := s.inflight.acquire(ctx); err != nil {
+        result.Status = "连接失败"
+        result.Reason = classifyError(err)
+        return result
+    }
+    defer s.inflight.release()
+
+    // benchTimeout 限制包括生成在内的总时长，benchConnectTimeout 只限制建立连接到收到响应头，
+    // 区分服务不可达和正在生成较长的回答
+    reqCtx, cancel := context.WithTimeout(ctx, s.cfg.BenchTimeout)
+    defer cancel()
+    connectCtx, cancelConnect := context.WithCancelCause(reqCtx)
+    defer cancelConnect(nil)
+    connectTimer := time.AfterFunc(s.cfg.BenchConnectTimeout, func() { cancelConnect(errConnectTimeout) })
+
+    resp, err := s.benchClient.Do(req.WithContext(connectCtx))
+    connectTimer.Stop()
+    if err != nil {
+        result.Status = "连接失败"
+        result.Reason = classifyError(err)
+        if errors.Is(context.Cause(connectCtx), errConnectTimeout) {
+            err = errConnectTimeout
+            result.Reason = "超时"
+        }
+        slog.Warn("测试连接失败", "ip", ip, "port", port, "model", modelName, "error", err)
+        return result
+    }
+    defer resp.Body.Close()
+
+    if resp.StatusCode != http.StatusOK {
+        result.Status = fmt.Sprintf("HTTP %d", resp.StatusCode)
+        result.Reason = result.Status
+        if s.authRejected(ip, port, &statusError{code: resp.StatusCode}) {
+            result.Status = "令牌被拒绝"
+        }
+        return result
+    }
+
+    scanner := bufio.NewScanner(resp.Body)
+    var (
+        firstToken time.Time
+        lastToken  time.Time
+        tokenCount int
+        response   []rune
+        final      bool
+        usageCount int // OpenAI 接口 usage 中的生成Token数
+    )
+    keep := func(text string) {
+        if s.cfg.SaveResponse && len(response) < s.cfg.SaveResponseLength {
+            // 保留生成的文本用于核实服务真实性，超出长度的部分丢弃
+            response = append(response, []rune(text)...)
+            if len(response) > s.cfg.SaveResponseLength {
+                response = response[:s.cfg.SaveResponseLength]
+            }
+        }
+    }
+
+    for scanner.Scan() {
+        // SSE 响应只有带文本的数据块计为Token，首块通常只有角色，usage 块没有文本
+        var chunk openAIChunk
+        if openai {
+            var ok, done bool
+            chunk, ok, done = parseSSELine(scanner.Bytes())
+            if done {
+                break
+            }
+            if ok && chunk.Usage != nil {
+                usageCount = chunk.Usage.CompletionTokens
+                result.EvalCount = chunk.Usage.CompletionTokens
+                result.PromptEvalCount = chunk.Usage.PromptTokens
+            }
+            if !ok || chunk.text() == "" {
+                continue
+            }
+        }
+        if tokenCount == 0 {
+            firstToken = time.Now()
+        }
+        lastToken = time.Now()
+        tokenCount++
+        if s.cfg.QuickBench {
+            // 快速模式读到首个响应即停止
+            break
+        }
+        if openai {
+            keep(chunk.text())
+            continue
+        }
+
+        var data map[string]interface{}
+        if err := json.Unmarshal(scanner.Bytes(), &data); err != nil {
+            continue
+        }
+        keep(responseText(data))
+
+        if done, _ := data["done"].(bool); done {
+            // 结束帧中服务端统计的生成Token数和耗时（纳秒）比墙钟估算更准确
+            evalCount, _ := data["eval_count"].(float64)
+            evalDuration, _ := data["eval_duration"].(float64)
+            if evalCount > 0 && evalDuration > 0 {
+                final = true
+                result.EvalCount = int(evalCount)
+                result.EvalTokensPerSec = evalCount / time.Duration(evalDuration).Seconds()
+            }
+            if promptCount, ok := data["prompt_eval_count"].(float64); ok {
+                result.PromptEvalCount = int(promptCount)
+            }
+            // 结束帧中的 load_duration 为模型加载耗时（纳秒），用于判断是否冷启动
+            if load, ok := data["load_duration"].(float64); ok {
+                result.LoadDurationMs = time.Duration(load).Milliseconds()
+                if s.cfg.ColdStartThreshold > 0 {
+                    cold := time.Duration(load) >= s.cfg.ColdStartThreshold
+                    result.ColdStart = &cold
+                }
+            }
+            break
+        }
+    }
+    // 收到响应后超过总时长，说明服务正常但生成时间过长
+    timedOut := scanner.Err() != nil && errors.Is(reqCtx.Err(), context.DeadlineExceeded)
+
+    if tokenCount == 0 {
+        if timedOut {
+            result.Status = "生成超时"
+            result.Reason = result.Status
+            return result
+        }
+        result.Status = "无响应"
+        result.Reason = result.Status
+        return result
+    }
+
+    latency := firstToken.Sub(start)
+    result.Status = "成功"
+    result.Response = string(response)
+    result.FirstTokenMs = latency.Milliseconds()
+
+    // 打印成功测试结果
+    if s.cfg.QuickBench {
+        slog.Info("成功测试", "ip", ip, "port", port, "model", modelName, "first_token_ms", latency.Milliseconds())
+        return result
+    }
+    totalTime := lastToken.Sub(start)
+    // usage 中的Token数比数据块数量准确，一个数据块可能包含多个Token
+    if usageCount > 0 {
+        tokenCount = usageCount
+    }
+    result.WallTokensPerSec = float64(tokenCount) / totalTime.Seconds()
+    // 优先使用服务端统计的速度，缺少结束帧时退回墙钟估算
+    result.TokensPerSec = result.WallTokensPerSec
+    if final {
+        result.TokensPerSec = result.EvalTokensPerSec
+    }
+
+    if timedOut {
+        // 保留已生成部分的速度，状态单独标记，不计入成功结果
+        result.Status = "生成超时"
+        result.Reason = result.Status
+        slog.Warn("生成超时", "ip", ip, "port", port, "model", modelName, "tps", result.TokensPerSec)
+        return result
+    }
+
+    // 超出合理范围的速度多半是测量误差，标记为可疑留待人工复核
+    if !s.plausibleTps(result.TokensPerSec) {
+        result.Status = "可疑"
+        result.Reason = "速度超出合理范围"
+        slog.Warn("可疑结果", "ip", ip, "port", port, "model", modelName, "tps", result.TokensPerSec)
+        return result
+    }
+    slog.Info("成功测试",
+        "ip", ip,
+        "port", port,
+        "model", modelName,
+        "first_token_ms", latency.Milliseconds(),
+        "tps", result.TokensPerSec)
+    return result
+}
+
+// 是否开启多路测试，快速模式不测生成速度，不做多路测试
+func (c *Config) multiStream() bool {
+    return c.MultiStreams > 1 && !c.QuickBench
+}
+
+// 判断历史失败使用的结果文件，未单独指定时与增量测试共用 priorResultsFile
+func (c *Config) previousResultsFile() string {
+    if c.PreviousResultsFile != "" {
+        return c.PreviousResultsFile
+    }
+    return c.PriorResultsFile
+}
+
+// 判断生成速度是否在配置的合理范围内
+func (s *Scanner) plausibleTps(tps float64) bool {
+    if s.cfg.MinPlausibleTps > 0 && tps < s.cfg.MinPlausibleTps {
+        return false
+    }
+    if s.cfg.MaxPlausibleTps > 0 && tps > s.cfg.MaxPlausibleTps {
+        return false
+    }
+    return true
+}
@@ -0,0 +1,305 @@
+package scan
+
+import (
+    "context"
+    "fmt"
+    "net/http"
+    "os"
+    "path/filepath"
+    "testing"
+    "time"
+)
+
+// 返回按固定内容流式输出的生成接口，frames 为逐行输出的响应帧，每帧之间间隔 delay
+func streamHandler(delay time.Duration, frames ...string) http.HandlerFunc {
+    return func(w http.ResponseWriter, r *http.Request) {
+        flusher := w.(http.Flusher)
+        for _, frame := range frames {
+            time.Sleep(delay)
+            fmt.Fprintln(w, frame)
+            flusher.Flush()
+        }
+    }
+}
+
+func TestBenchmarkModel(t *testing.T) {
+    mux := http.NewServeMux()
+    // 服务端统计生成10个Token耗时2秒，速度应为5 Tokens/s，与墙钟时间无关
+    mux.HandleFunc("/api/generate", streamHandler(20*time.Millisecond,
+        `{"response":"你好","done":false}`,
+        `{"response":"，","done":false}`,
+        `{"response":"世界","done":false}`,
+        `{"response":"","done":true,"eval_count":10,"eval_duration":2000000000,"prompt_eval_count":3,"load_duration":1500000000}`,
+    ))
+    ip, port := fakeOllama(t, mux)
+    s := newTestScanner(t, func(cfg *Config) {
+        cfg.SaveResponse = true
+        cfg.ColdStartThreshold = time.Second
+    })
+
+    result := s.benchmarkModel(context.Background(), ip, port, "llama3:8b", "hi")
+    if result.Status != "成功" {
+        t.Fatalf("状态为 %q，应为成功", result.Status)
+    }
+    if result.FirstTokenMs < 20 {
+        t.Errorf("首Token延迟为 %dms，应不小于首帧前的20ms", result.FirstTokenMs)
+    }
+    if result.EvalTokensPerSec != 5 || result.TokensPerSec != 5 {
+        t.Errorf("速度为 %v（服务端 %v），应为 5", result.TokensPerSec, result.EvalTokensPerSec)
+    }
+    if result.EvalCount != 10 || result.PromptEvalCount != 3 {
+        t.Errorf("Token数为 %d/%d，应为 10/3", result.EvalCount, result.PromptEvalCount)
+    }
+    if result.Response != "你好，世界" {
+        t.Errorf("响应内容为 %q", result.Response)
+    }
+    if result.LoadDurationMs != 1500 || result.ColdStart == nil || !*result.ColdStart {
+        t.Errorf("加载耗时为 %dms，冷启动为 %v", result.LoadDurationMs, result.ColdStart)
+    }
+}
+
+func TestBenchmarkModelChat(t *testing.T) {
+    mux := http.NewServeMux()
+    mux.HandleFunc("/api/chat", streamHandler(0,
+        `{"message":{"role":"assistant","content":"你好"},"done":false}`,
+        `{"message":{"role":"assistant","content":""},"done":true,"eval_count":4,"eval_duration":1000000000}`,
+    ))
+    ip, port := fakeOllama(t, mux)
+    s := newTestScanner(t, func(cfg *Config) {
+        cfg.BenchEndpoint = benchEndpointChat
+        cfg.SaveResponse = true
+    })
+
+    result := s.benchmarkModel(context.Background(), ip, port, "llama3:8b", "hi")
+    if result.Status != "成功" || result.TokensPerSec != 4 || result.Response != "你好" {
+        t.Errorf("结果为 %+v", result)
+    }
+}
+
+func TestBenchmarkModelOpenAI(t *testing.T) {
+    mux := http.NewServeMux()
+    // 首块只有角色，最后一块附带 usage，[DONE] 之后的数据应被忽略
+    mux.HandleFunc("/v1/chat/completions", streamHandler(10*time.Millisecond,
+        `data: {"choices":[{"delta":{"role":"assistant"}}]}`, ``,
+        `data: {"choices":[{"delta":{"content":"你好"}}]}`, ``,
+        `: keep-alive`,
+        `data: {"choices":[{"delta":{"content":"世界"}}]}`, ``,
+        `data: {"choices":[],"usage":{"prompt_tokens":3,"completion_tokens":6}}`, ``,
+        `data: [DONE]`, ``,
+        `data: {"choices":[{"delta":{"content":"多余"}}]}`,
+    ))
+    ip, port := fakeOllama(t, mux)
+    s := newTestScanner(t, func(cfg *Config) {
+        cfg.BenchEndpoint = benchEndpointOpenAI
+        cfg.SaveResponse = true
+    })
+
+    result := s.benchmarkModel(context.Background(), ip, port, "Qwen/Qwen2-7B", "hi")
+    if result.Status != "成功" || result.Response != "你好世界" {
+        t.Fatalf("结果为 %+v", result)
+    }
+    // 首Token以第一个带文本的数据块为准，不算只有角色的首块
+    if result.FirstTokenMs < 30 {
+        t.Errorf("首Token延迟为 %dms，应不小于30ms", result.FirstTokenMs)
+    }
+    if result.EvalCount != 6 || result.PromptEvalCount != 3 || result.EvalTokensPerSec != 0 {
+        t.Errorf("Token统计为 %d/%d，服务端速度为 %v", result.EvalCount, result.PromptEvalCount, result.EvalTokensPerSec)
+    }
+    if result.TokensPerSec != result.WallTokensPerSec || result.TokensPerSec <= 0 {
+        t.Errorf("速度为 %v，墙钟速度为 %v", result.TokensPerSec, result.WallTokensPerSec)
+    }
+}
+
+func TestBenchmarkModelWithoutFinalFrame(t *testing.T) {
+    mux := http.NewServeMux()
+    mux.HandleFunc("/api/generate", streamHandler(10*time.Millisecond,
+        `{"response":"a","done":false}`,
+        `{"response":"b","done":false}`,
+    ))
+    ip, port := fakeOllama(t, mux)
+    s := newTestScanner(t, nil)
+
+    // 缺少结束帧时退回墙钟估算
+    result := s.benchmarkModel(context.Background(), ip, port, "llama3:8b", "hi")
+    if result.Status != "成功" || result.EvalTokensPerSec != 0 {
+        t.Fatalf("结果为 %+v", result)
+    }
+    if result.TokensPerSec != result.WallTokensPerSec || result.TokensPerSec <= 0 {
+        t.Errorf("速度为 %v，墙钟速度为 %v", result.TokensPerSec, result.WallTokensPerSec)
+    }
+}
+
+func TestBenchmarkModelErrors(t *testing.T) {
+    tests := []struct {
+        name    string
+        handler http.HandlerFunc
+        status  string
+    }{
+        {
+            name: "非200响应",
+            handler: func(w http.ResponseWriter, r *http.Request) {
+                http.Error(w, "model not found", http.StatusNotFound)
+            },
+            status: "HTTP 404",
+        },
+        {
+            name:    "空响应",
+            handler: func(w http.ResponseWriter, r *http.Request) {},
+            status:  "无响应",
+        },
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            mux := http.NewServeMux()
+            mux.HandleFunc("/api/generate", tt.handler)
+            ip, port := fakeOllama(t, mux)
+            s := newTestScanner(t, nil)
+
+            result := s.benchmarkModel(context.Background(), ip, port, "llama3:8b", "hi")
+            if result.Status != tt.status {
+                t.Errorf("状态为 %q，应为 %q", result.Status, tt.status)
+            }
+        })
+    }
+}
+
+func TestPercentile(t *testing.T) {
+    tests := []struct {
+        values []float64
+        p      float64
+        want   float64
+    }{
+        {nil, 0.5, 0},
+        {[]float64{7}, 0.95, 7},
+        {[]float64{3, 1, 2}, 0.5, 2},
+        {[]float64{4, 1, 3, 2}, 0.5, 2.5},
+        {[]float64{10, 20, 30, 40, 50}, 0.95, 48},
+        {[]float64{10, 20, 30, 40, 50}, 0.05, 12},
+    }
+    for _, tt := range tests {
+        if got := percentile(tt.values, tt.p); got != tt.want {
+            t.Errorf("percentile(%v, %v) = %v，应为 %v", tt.values, tt.p, got, tt.want)
+        }
+    }
+}
+
+func TestLoadPriorFailures(t *testing.T) {
+    path := filepath.Join(t.TempDir(), "results.csv")
+    data := "ip,port,model,status,first_token_ms,tokens_per_sec,reason\n" +
+        "10.0.0.1,11434,llama3,连接失败,0,0,连接被拒绝\n" +
+        "10.0.0.2,11434,llama3,连接失败,0,0,超时\n" +
+        "10.0.0.3,11434,llama3,HTTP 404,0,0,HTTP 404\n" +
+        "10.0.0.4,11434,llama3,令牌被拒绝,0,0,HTTP 401\n" +
+        "10.0.0.5,11434,llama3,连接失败,0,0,连接被拒绝\n" +
+        "10.0.0.5,11434,llama3,成功,120,42.00,\n"
+    if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+        t.Fatal(err)
+    }
+
+    failures, err := loadPriorFailures(path, outputCSV)
+    if err != nil {
+        t.Fatalf("读取历史失败记录出错: %v", err)
+    }
+    want := map[string]bool{
+        checkpointKey("10.0.0.1:11434", "llama3"): true,
+        checkpointKey("10.0.0.3:11434", "llama3"): true,
+        checkpointKey("10.0.0.4:11434", "llama3"): true,
+    }
+    if len(failures) != len(want) {
+        t.Fatalf("失败组合数为 %d，应为 %d: %v", len(failures), len(want), failures)
+    }
+    for key := range want {
+        if !failures[key] {
+            t.Errorf("%s 应被跳过", key)
+        }
+    }
+}
+
+func TestLoadPriorJSONL(t *testing.T) {
+    path := filepath.Join(t.TempDir(), "results.jsonl")
+    data := `{"ip":"10.0.0.1","port":11434,"model":"llama3","status":"连接失败","reason":"连接被拒绝"}
+{"ip":"10.0.0.2","port":11434,"model":"llama3","status":"连接失败","reason":"超时"}
+
+{"ip":"10.0.0.3","port":11434,"model":"llama3","status":"成功","tokens_per_sec":42.5}
+`
+    if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+        t.Fatal(err)
+    }
+
+    // 扩展名为 .jsonl 时不依赖配置的输出格式
+    failures, err := loadPriorFailures(path, outputCSV)
+    if err != nil {
+        t.Fatalf("读取历史失败记录出错: %v", err)
+    }
+    if len(failures) != 1 || !failures[checkpointKey("10.0.0.1:11434", "llama3")] {
+        t.Errorf("失败组合为 %v，应只有 10.0.0.1", failures)
+    }
+
+    prior, err := loadPriorResults(path, outputCSV)
+    if err != nil {
+        t.Fatalf("读取历史测试结果出错: %v", err)
+    }
+    if got := prior[checkpointKey("10.0.0.3:11434", "llama3")]; got != 42.5 || len(prior) != 1 {
+        t.Errorf("历史速度为 %v", prior)
+    }
+}
+
+func TestLoadBenchTargetsServiceType(t *testing.T) {
+    path := filepath.Join(t.TempDir(), "ollama.csv")
+    data := "ip,port,model,status,service_type\n" +
+        "10.0.0.1,11434,llama3,成功,ollama\n" +
+        "10.0.0.2,8000,Qwen/Qwen2-7B,成功,vllm\n" +
+        "10.0.0.3,1234,phi3,成功,openai-compat\n"
+    if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+        t.Fatal(err)
+    }
+
+    // Ollama 接口只测试 Ollama 服务，openai 接口可以测试全部服务
+    for endpoint, want := range map[string]int{benchEndpointGenerate: 1, benchEndpointOpenAI: 3} {
+        s := newTestScanner(t, func(cfg *Config) {
+            cfg.OllamaOutputFile = path
+            cfg.BenchEndpoint = endpoint
+        })
+        targets, err := s.loadBenchTargets()
+        if err != nil {
+            t.Fatal(err)
+        }
+        if len(targets) != want {
+            t.Errorf("%s 接口的测试目标为 %v，应有 %d 个", endpoint, targets, want)
+        }
+    }
+}
+
+func TestWriteEfficiencyJSONL(t *testing.T) {
+    dir := t.TempDir()
+    results := filepath.Join(dir, "results.jsonl")
+    data := `{"ip":"10.0.0.1","port":11434,"model":"llama3","status":"成功","first_token_ms":100,"tokens_per_sec":50}
+{"ip":"10.0.0.2","port":11434,"model":"llama3","status":"成功","first_token_ms":200,"tokens_per_sec":100}
+{"ip":"10.0.0.3","port":11434,"model":"llama3","status":"连接失败"}
+`
+    if err := os.WriteFile(results, []byte(data), 0o600); err != nil {
+        t.Fatal(err)
+    }
+    path := filepath.Join(dir, "efficiency.csv")
+    s := newTestScanner(t, func(cfg *Config) {
+        cfg.OutputFormat = outputJSONL
+        cfg.EfficiencyFile = path
+        cfg.RedactIP = redactMask
+        cfg.HostCosts = []HostCost{{IP: "10.0.0.2", CostPerHour: 1}}
+    })
+    if err := s.writeEfficiency(results); err != nil {
+        t.Fatal(err)
+    }
+
+    got, err := os.ReadFile(path)
+    if err != nil {
+        t.Fatal(err)
+    }
+    // 成本按真实IP匹配，写出的IP已脱敏
+    want := "IP地址,端口,模型名称,首Token延迟(ms),Tokens/s,效率得分,每美元Tokens\n" +
+        "10.0.0.x,11434,llama3,200,100.00,85.0,360000\n" +
+        "10.0.0.x,11434,llama3,100,50.00,65.0,\n"
+    if string(got) != want {
+        t.Errorf("效率排名为:\n%s\n应为:\n%s", got, want)
+    }
+}
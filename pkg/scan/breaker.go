@@ -0,0 +1,100 @@
+package scan
+
+import (
+    "fmt"
+    "net"
+    "sync"
+    "time"
+)
+
+// 单个子网的熔断状态
+type breakerState struct {
+    failures  int       // 连续连接失败次数
+    openUntil time.Time // 熔断冷却截止时间
+    probing   bool      // 冷却结束后是否已有探测请求在途
+}
+
+// 子网熔断器，同一子网连续失败达到阈值后暂停探测
+type subnetBreaker struct {
+    threshold int
+    cooldown  time.Duration
+    mu        sync.Mutex
+    subnets   map[string]*breakerState
+}
+
+// 创建熔断器，阈值小于等于0时不启用
+func newSubnetBreaker(threshold int, cooldown time.Duration) *subnetBreaker {
+    if threshold <= 0 {
+        return nil
+    }
+    return &subnetBreaker{
+        threshold: threshold,
+        cooldown:  cooldown,
+        subnets:   make(map[string]*breakerState),
+    }
+}
+
+// 计算IP所属子网，IPv4按/24划分，IPv6按/64划分
+func subnetKey(ip string) string {
+    return maskIP(ip, 24, 64)
+}
+
+// 按给定前缀长度计算IP所属网段，无法解析时原样返回
+func maskIP(ip string, v4Bits, v6Bits int) string {
+    parsed := net.ParseIP(ip)
+    if parsed == nil {
+        return ip
+    }
+    if v4 := parsed.To4(); v4 != nil {
+        return fmt.Sprintf("%s/%d", v4.Mask(net.CIDRMask(v4Bits, 32)), v4Bits)
+    }
+    return fmt.Sprintf("%s/%d", parsed.Mask(net.CIDRMask(v6Bits, 128)), v6Bits)
+}
+
+// 判断是否允许探测该IP，冷却结束后只放行一个试探请求
+func (b *subnetBreaker) allow(ip string) bool {
+    if b == nil {
+        return true
+    }
+    b.mu.Lock()
+    defer b.mu.Unlock()
+
+    state, ok := b.subnets[subnetKey(ip)]
+    if !ok || state.failures < b.threshold {
+        return true
+    }
+    if state.probing || time.Now().Before(state.openUntil) {
+        return false
+    }
+    state.probing = true
+    return true
+}
+
+// 记录探测结果，返回本次失败是否触发了熔断
+func (b *subnetBreaker) record(ip string, success bool) bool {
+    if b == nil {
+        return false
+    }
+    b.mu.Lock()
+    defer b.mu.Unlock()
+
+    key := subnetKey(ip)
+    if success {
+        delete(b.subnets, key)
+        return false
+    }
+
+    state, ok := b.subnets[key]
+    if !ok {
+        state = &breakerState{}
+        b.subnets[key] = state
+    }
+    state.failures++
+    // 首次达到阈值或试探请求失败时（重新）进入冷却
+    if state.failures == b.threshold || state.probing {
+        state.openUntil = time.Now().Add(b.cooldown)
+        state.probing = false
+        return true
+    }
+    return false
+}
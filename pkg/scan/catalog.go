@@ -0,0 +1,89 @@
+package scan
+
+import (
+    "encoding/csv"
+    "fmt"
+    "log/slog"
+    "os"
+    "sort"
+    "strconv"
+    "strings"
+    "sync"
+)
+
+// 模型目录，汇总检测到的每个模型的主机数和示例地址
+type modelCatalog struct {
+    mu       sync.Mutex
+    examples int
+    models   map[string]*catalogEntry
+}
+
+type catalogEntry struct {
+    hosts    int
+    examples []string
+}
+
+// 创建模型目录，未配置 catalogFile 时返回nil
+func newModelCatalog(path string, examples int) *modelCatalog {
+    if path == "" {
+        return nil
+    }
+    return &modelCatalog{examples: examples, models: make(map[string]*catalogEntry)}
+}
+
+// 记录一个提供该模型的地址
+func (c *modelCatalog) add(model, endpoint string) {
+    if c == nil {
+        return
+    }
+    c.mu.Lock()
+    defer c.mu.Unlock()
+    entry, ok := c.models[model]
+    if !ok {
+        entry = &catalogEntry{}
+        c.models[model] = entry
+    }
+    entry.hosts++
+    if len(entry.examples) < c.examples {
+        entry.examples = append(entry.examples, endpoint)
+    }
+}
+
+// 按主机数从多到少写入目录文件
+func (c *modelCatalog) write(path string, mode os.FileMode, lang string) error {
+    if c == nil {
+        return nil
+    }
+    c.mu.Lock()
+    defer c.mu.Unlock()
+
+    names := make([]string, 0, len(c.models))
+    for name := range c.models {
+        names = append(names, name)
+    }
+    sort.Slice(names, func(i, j int) bool {
+        if c.models[names[i]].hosts != c.models[names[j]].hosts {
+            return c.models[names[i]].hosts > c.models[names[j]].hosts
+        }
+        return names[i] < names[j]
+    })
+
+    file, err := createFile(path, mode)
+    if err != nil {
+        return fmt.Errorf("创建模型目录文件失败: %w", err)
+    }
+    defer file.Close()
+
+    writer := csv.NewWriter(file)
+    writer.Write(localizeHeader(lang, []string{"模型名称", "主机数", "示例地址"}))
+    for _, name := range names {
+        entry := c.models[name]
+        writer.Write([]string{name, strconv.Itoa(entry.hosts), strings.Join(entry.examples, " ")})
+    }
+    writer.Flush()
+    if err := writer.Error(); err != nil {
+        return fmt.Errorf("写入模型目录文件失败: %w", err)
+    }
+    slog.Info("模型目录已保存", "models", len(names), "path", path)
+    return nil
+}
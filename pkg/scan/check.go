@@ -0,0 +1,80 @@
+package scan
+
+import (
+    "context"
+    "fmt"
+    "os"
+    "os/exec"
+    "strings"
+    "time"
+)
+
+// 单项运行前检查的结果，Err 为空表示通过
+type CheckResult struct {
+    Name   string
+    Detail string
+    Err    error
+}
+
+// 长时间运行前的环境检查：配置校验、扫描程序是否安装、sudo 能否免密执行、输入文件是否可读
+// 每一项都会执行，不因前一项失败而中止，调用方据此打印完整的检查报告
+func Preflight(ctx context.Context, cfg *Config) []CheckResult {
+    var results []CheckResult
+    add := func(name, detail string, err error) {
+        results = append(results, CheckResult{Name: name, Detail: detail, Err: err})
+    }
+
+    add("配置校验", "全部配置项有效", cfg.Validate())
+
+    // 原生扫描不依赖外部程序，也不需要 root 权限
+    if cfg.Scanner == scannerNative {
+        add("扫描程序", "使用原生扫描，无需外部程序", nil)
+    } else if err := validateScanner(cfg.Scanner); err != nil {
+        add("扫描程序", "", err)
+    } else {
+        path, err := exec.LookPath(cfg.Scanner)
+        if err != nil {
+            err = fmt.Errorf("未找到扫描程序 %s，请确认已安装并加入PATH: %w", cfg.Scanner, err)
+        }
+        add("扫描程序", path, err)
+        add("sudo", "可免密执行", checkSudo(ctx))
+    }
+
+    inputs := []string{cfg.InputFile}
+    for _, job := range cfg.Jobs {
+        inputs = append(inputs, job.InputFile)
+    }
+    for _, input := range inputs {
+        if input == "" || input == stdinPath {
+            continue
+        }
+        add("输入文件", input, checkReadable(input))
+    }
+    return results
+}
+
+// 确认 sudo 能以非交互方式执行，扫描程序运行时不会停下来等待输入密码
+func checkSudo(ctx context.Context) error {
+    if _, err := exec.LookPath("sudo"); err != nil {
+        return fmt.Errorf("未找到 sudo: %w", err)
+    }
+    ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+    defer cancel()
+    out, err := exec.CommandContext(ctx, "sudo", "-n", "true").CombinedOutput()
+    if err != nil {
+        if msg := strings.TrimSpace(string(out)); msg != "" {
+            return fmt.Errorf("sudo 需要输入密码，请配置免密 sudo: %s", msg)
+        }
+        return fmt.Errorf("sudo 需要输入密码，请配置免密 sudo: %w", err)
+    }
+    return nil
+}
+
+// 确认文件存在且可读
+func checkReadable(path string) error {
+    file, err := os.Open(path)
+    if err != nil {
+        return fmt.Errorf("无法读取: %w", err)
+    }
+    return file.Close()
+}
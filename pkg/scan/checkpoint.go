@@ -0,0 +1,194 @@
+package scan
+
+import (
+    "crypto/sha256"
+    "encoding/hex"
+    "encoding/json"
+    "errors"
+    "io"
+    "io/fs"
+    "log/slog"
+    "os"
+    "sync"
+)
+
+// 性能测试断点，记录已完成的(IP, 模型)组合
+type checkpoint struct {
+    path  string
+    mode  os.FileMode
+    input string // 输入文件的内容摘要，输入变化后断点失效
+    mu    sync.Mutex
+    done  map[string]bool
+}
+
+// 断点文件内容
+type checkpointData struct {
+    Input     string   `json:"input,omitempty"`
+    Completed []string `json:"completed"`
+}
+
+// 生成断点记录键，host 为 "IP:端口"，同一主机不同端口上的服务分别记录
+func checkpointKey(host, model string) string {
+    return host + "|" + model
+}
+
+// 加载断点文件，文件不存在时返回空断点
+func loadCheckpoint(path string, mode os.FileMode) (*checkpoint, error) {
+    cp := &checkpoint{path: path, mode: mode, done: make(map[string]bool)}
+    data, err := os.ReadFile(path)
+    if errors.Is(err, fs.ErrNotExist) {
+        return cp, nil
+    }
+    if err != nil {
+        return nil, err
+    }
+
+    var saved checkpointData
+    if err := json.Unmarshal(data, &saved); err != nil {
+        return nil, err
+    }
+    cp.input = saved.Input
+    for _, key := range saved.Completed {
+        cp.done[key] = true
+    }
+    return cp, nil
+}
+
+// 判断组合是否已完成，未启用断点（nil）时总是返回false
+func (c *checkpoint) has(key string) bool {
+    if c == nil {
+        return false
+    }
+    c.mu.Lock()
+    defer c.mu.Unlock()
+    return c.done[key]
+}
+
+// 标记组合已完成
+func (c *checkpoint) mark(key string) {
+    if c == nil {
+        return
+    }
+    c.mu.Lock()
+    defer c.mu.Unlock()
+    c.done[key] = true
+}
+
+// 已完成的组合数量
+func (c *checkpoint) count() int {
+    if c == nil {
+        return 0
+    }
+    c.mu.Lock()
+    defer c.mu.Unlock()
+    return len(c.done)
+}
+
+// 写入断点文件，避免崩溃时留下半截文件
+func (c *checkpoint) save() error {
+    if c == nil {
+        return nil
+    }
+    c.mu.Lock()
+    saved := checkpointData{Input: c.input, Completed: make([]string, 0, len(c.done))}
+    for key := range c.done {
+        saved.Completed = append(saved.Completed, key)
+    }
+    c.mu.Unlock()
+
+    data, err := json.Marshal(saved)
+    if err != nil {
+        return err
+    }
+    return writeFileAtomic(c.path, data, c.mode)
+}
+
+// 加载服务检测断点，未配置 detectCheckpointFile 时返回nil
+// 断点按扫描结果文件的内容摘要区分，扫描结果变化后从头探测
+func (s *Scanner) loadDetectCheckpoint() (*checkpoint, error) {
+    if s.cfg.DetectCheckpointFile == "" {
+        return nil, nil
+    }
+    if s.cfg.ScanOutputFile == stdinPath {
+        slog.Warn("从标准输入读取目标时不支持检测断点")
+        return nil, nil
+    }
+    cp, stale, err := loadCheckpointFor(s.cfg.DetectCheckpointFile, s.cfg.ScanOutputFile, s.fileMode)
+    if err != nil {
+        return nil, err
+    }
+    if stale {
+        slog.Warn("扫描结果已变化，忽略检测断点")
+    }
+    if cp.count() > 0 {
+        slog.Info("从检测断点继续", "completed", cp.count())
+    }
+    return cp, nil
+}
+
+// 加载性能测试断点，未开启 resume 时返回nil，不记录断点
+// 断点按测试输入的内容摘要区分，输入变化后从头测试；流水线模式下 input 为扫描结果文件
+func (s *Scanner) loadBenchCheckpoint(input string) (*checkpoint, error) {
+    if !s.cfg.Resume {
+        return nil, nil
+    }
+    if input == stdinPath {
+        slog.Warn("从标准输入读取目标时不支持测试断点")
+        return nil, nil
+    }
+    cp, stale, err := loadCheckpointFor(s.cfg.CheckpointFile, input, s.fileMode)
+    if err != nil {
+        return nil, err
+    }
+    if stale {
+        slog.Warn("测试输入已变化，忽略断点", "input", input)
+    }
+    slog.Info("从断点续测", "completed", cp.count())
+    return cp, nil
+}
+
+// 加载与输入文件对应的断点，断点记录的摘要与输入不一致时返回空断点，stale 表示丢弃了已有记录
+func loadCheckpointFor(path, input string, mode os.FileMode) (cp *checkpoint, stale bool, err error) {
+    digest, err := fileDigest(input)
+    if err != nil {
+        return nil, false, err
+    }
+    cp, err = loadCheckpoint(path, mode)
+    if err != nil {
+        return nil, false, err
+    }
+    if cp.input != digest {
+        stale = cp.count() > 0
+        cp = &checkpoint{path: cp.path, mode: cp.mode, done: make(map[string]bool)}
+    }
+    cp.input = digest
+    return cp, stale, nil
+}
+
+// 全部完成后删除断点，下次从头开始；中断时保存断点供续测使用
+func (c *checkpoint) finish(interrupted bool) error {
+    if c == nil {
+        return nil
+    }
+    if interrupted {
+        return c.save()
+    }
+    if err := os.Remove(c.path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+        return err
+    }
+    return nil
+}
+
+// 计算文件内容的 SHA-256 摘要
+func fileDigest(path string) (string, error) {
+    file, err := os.Open(path)
+    if err != nil {
+        return "", err
+    }
+    defer file.Close()
+    hash := sha256.New()
+    if _, err := io.Copy(hash, file); err != nil {
+        return "", err
+    }
+    return hex.EncodeToString(hash.Sum(nil)), nil
+}
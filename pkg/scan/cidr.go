@@ -0,0 +1,80 @@
+package scan
+
+import (
+    "fmt"
+    "math"
+    "math/bits"
+    "net"
+    "strings"
+)
+
+// 解析CIDR格式的网段
+func parseNetwork(line string) (*net.IPNet, error) {
+    _, network, err := net.ParseCIDR(line)
+    if err != nil {
+        return nil, fmt.Errorf("无效的IP或网段: %s", line)
+    }
+    return network, nil
+}
+
+// 返回下一个IP地址，到达地址空间末尾时返回nil
+func nextIP(ip net.IP) net.IP {
+    next := make(net.IP, len(ip))
+    copy(next, ip)
+    for i := len(next) - 1; i >= 0; i-- {
+        next[i]++
+        if next[i] != 0 {
+            return next
+        }
+    }
+    return nil
+}
+
+// 逐个展开目标行中的地址，单个IP原样返回，CIDR网段按顺序流式展开，不在内存中生成完整列表，
+// 网段包含网络地址和广播地址，/31 展开为2个地址，/32 为1个，fn 返回false时停止并返回false
+func eachIP(line string, fn func(ip string) bool) (bool, error) {
+    if ip := net.ParseIP(line); ip != nil {
+        return fn(ip.String()), nil
+    }
+    network, err := parseNetwork(line)
+    if err != nil {
+        return true, err
+    }
+    for ip := network.IP.Mask(network.Mask); ip != nil && network.Contains(ip); ip = nextIP(ip) {
+        if !fn(ip.String()) {
+            return false, nil
+        }
+    }
+    return true, nil
+}
+
+// 目标行展开后的地址数，非网段的行计为1，超大的IPv6网段按int上限计
+func countIPs(line string) int {
+    if !strings.Contains(line, "/") {
+        return 1
+    }
+    network, err := parseNetwork(line)
+    if err != nil {
+        return 1
+    }
+    ones, size := network.Mask.Size()
+    if size-ones >= bits.UintSize-1 {
+        return math.MaxInt
+    }
+    return 1 << (size - ones)
+}
+
+// 饱和加法和乘法，目标数只用于进度显示，超大网段按int上限计，不能溢出为负数
+func saturatingAdd(a, b int) int {
+    if a > math.MaxInt-b {
+        return math.MaxInt
+    }
+    return a + b
+}
+
+func saturatingMul(a, b int) int {
+    if a != 0 && b > math.MaxInt/a {
+        return math.MaxInt
+    }
+    return a * b
+}
@@ -0,0 +1,79 @@
+package scan
+
+import (
+    "compress/gzip"
+    "fmt"
+    "io"
+    "os"
+    "strings"
+)
+
+// 以此结尾的文件按 gzip 格式读写
+const gzipSuffix = ".gz"
+
+// 判断文件是否按 gzip 压缩
+func isGzip(path string) bool {
+    return strings.HasSuffix(path, gzipSuffix)
+}
+
+// 解压读取，关闭时同时关闭底层文件
+type gzipReader struct {
+    *gzip.Reader
+    file *os.File
+}
+
+func (r *gzipReader) Close() error {
+    r.Reader.Close()
+    return r.file.Close()
+}
+
+// 打开文件用于读取，.gz 结尾时透明解压，续写产生的多段压缩数据会连续读出
+func openRead(path string) (io.ReadCloser, error) {
+    file, err := os.Open(path)
+    if err != nil {
+        return nil, err
+    }
+    if !isGzip(path) {
+        return file, nil
+    }
+    gz, err := gzip.NewReader(file)
+    if err != nil {
+        file.Close()
+        return nil, fmt.Errorf("解压 %s 失败: %w", path, err)
+    }
+    return &gzipReader{Reader: gz, file: file}, nil
+}
+
+// 压缩写入，关闭时先写完压缩尾部再关闭底层文件
+type gzipWriter struct {
+    *gzip.Writer
+    file *os.File
+}
+
+func (w *gzipWriter) Close() error {
+    if err := w.Writer.Close(); err != nil {
+        w.file.Close()
+        return err
+    }
+    return w.file.Close()
+}
+
+// 按指定权限创建输出文件，.gz 结尾时写入压缩数据
+func createOutput(path string, mode os.FileMode) (io.WriteCloser, error) {
+    file, err := createFile(path, mode)
+    if err != nil {
+        return nil, err
+    }
+    if !isGzip(path) {
+        return file, nil
+    }
+    return &gzipWriter{Writer: gzip.NewWriter(file), file: file}, nil
+}
+
+// 把写入器内部缓冲的压缩数据写到文件，普通文件无需处理
+func flushOutput(w io.Writer) error {
+    if f, ok := w.(interface{ Flush() error }); ok {
+        return f.Flush()
+    }
+    return nil
+}
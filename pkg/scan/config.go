@@ -0,0 +1,322 @@
+package scan
+
+import (
+    "errors"
+    "fmt"
+    "log/slog"
+    "reflect"
+
+    "github.com/spf13/viper"
+)
+
+// 设置各配置项的默认值
+func setDefaults(v *viper.Viper) {
+    // 设置zmap 默认值
+    v.SetDefault("port", 11434)
+    v.SetDefault("inputFile", "ips.txt")
+    v.SetDefault("outputFile", "results.csv") 
+    v.SetDefault("rate", 10000)
+    v.SetDefault("bandwidth", "100M")
+    
+    // 设置ollama默认值
+    v.SetDefault("maxWorkers", 100)
+    v.SetDefault("maxIdleConns", 100)
+    v.SetDefault("timeout", "5s")
+    v.SetDefault("idleConnTimeout", "90s")
+    v.SetDefault("probeEmbeddings", false)
+    
+    // 设置ollama性能测试默认值
+    v.SetDefault("benchTimeout", "30s")
+    v.SetDefault("benchPrompt", "用一句话自我介绍")
+    v.SetDefault("quickBench", false)
+
+    // 设置中间文件默认值
+    v.SetDefault("scanOutputFile", "ip.csv")
+    v.SetDefault("ollamaOutputFile", "ollama.csv") 
+
+    // 设置子网熔断默认值，阈值为0表示不启用
+    v.SetDefault("breakerThreshold", 0)
+    v.SetDefault("breakerCooldown", "60s")
+
+    // 设置链路追踪默认值，为空表示不启用
+    v.SetDefault("otelEndpoint", "")
+
+    // 设置模型列表解析失败重试默认值
+    v.SetDefault("decodeRetries", 2)
+    v.SetDefault("decodeRetryDelay", "500ms")
+
+    // 设置目标抽样默认值，样本数为0表示不抽样
+    v.SetDefault("sampleStrategy", "uniform")
+    v.SetDefault("sampleSize", 0)
+
+    // 设置全局在途请求上限默认值
+    v.SetDefault("maxInFlight", 0)
+
+    // 设置断点续测默认值
+    v.SetDefault("resume", false)
+    v.SetDefault("checkpointFile", "bench.checkpoint.json")
+    v.SetDefault("checkpointInterval", "10s")
+
+    // 设置单主机并发默认值，0表示不限制
+    v.SetDefault("maxPerHost", 0)
+    v.SetDefault("adaptiveThrottle", false)
+    v.SetDefault("throttleWindow", 3)
+    v.SetDefault("throttleTolerance", 0.2)
+
+    // 设置Kafka输出默认值，为空表示不启用
+    v.SetDefault("kafkaBrokers", []string{})
+    v.SetDefault("kafkaTopic", "")
+
+    // 设置阶段清单默认值，为空表示不输出
+    v.SetDefault("manifestDir", "")
+
+    // 设置流水线默认值
+    v.SetDefault("pipeline", false)
+    v.SetDefault("pipelineBuffer", 100)
+    v.SetDefault("scanPipeline", false)
+
+    // 设置失败原因汇总默认值
+    v.SetDefault("failureSummaryFile", "")
+
+    // 设置IP脱敏默认值
+    v.SetDefault("redactIP", "none")
+    v.SetDefault("redactSalt", "")
+
+    // 设置健康检查默认值，为空表示不启用
+    v.SetDefault("healthAddr", "")
+
+    // 设置目标列表预处理默认值，为空表示不处理
+    v.SetDefault("inputPreprocessor", "")
+
+    // 设置生成速度合理范围默认值，0表示不检查
+    v.SetDefault("minPlausibleTps", 0)
+    v.SetDefault("maxPlausibleTps", 0)
+
+    // 设置优雅退出默认值
+    v.SetDefault("drainTimeout", "10s")
+
+    // 设置响应头记录默认值，为空表示不记录
+    v.SetDefault("captureHeaders", []string{})
+
+    // 设置重试抖动默认值
+    v.SetDefault("retryJitter", true)
+
+    // 设置模型目录默认值，为空表示不输出
+    v.SetDefault("catalogFile", "")
+    v.SetDefault("catalogExamples", 3)
+
+    // 设置模型预热默认值
+    v.SetDefault("warmup", false)
+    v.SetDefault("warmupWorkers", 10)
+
+    // 设置输出文件权限默认值，扫描结果默认只允许所有者读写
+    v.SetDefault("outputFileMode", "0600")
+
+    // 设置多路并发测试默认值，0表示不测试
+    v.SetDefault("multiStreams", 0)
+
+    // 设置增量测试默认值，阈值为0表示不跳过
+    v.SetDefault("priorResultsFile", "")
+    v.SetDefault("skipIfFasterThan", 0)
+
+    // 设置优先探测默认值，为空表示按原顺序探测
+    v.SetDefault("priorDetectFile", "")
+
+    // 设置效率评分默认值，为空表示不输出
+    v.SetDefault("efficiencyFile", "")
+    v.SetDefault("efficiencyWeights.throughput", 0.7)
+    v.SetDefault("efficiencyWeights.latency", 0.3)
+    v.SetDefault("defaultCostPerHour", 0)
+
+    // 设置请求压缩默认值
+    v.SetDefault("compressRequests", false)
+
+    // 设置横幅抓取默认值
+    v.SetDefault("bannerGrab", false)
+    v.SetDefault("bannerBytes", 256)
+    v.SetDefault("bannerTimeout", "3s")
+
+    // 设置基线对比默认值，为空表示不对比
+    v.SetDefault("baselineFile", "")
+
+    // 设置探测点标签默认值，为空表示不输出
+    v.SetDefault("probeLabel", "")
+
+    // 设置受限状态码默认值
+    v.SetDefault("restrictedStatuses", []int{401, 403, 429})
+
+    // 设置冷启动判断默认值，0表示不判断
+    v.SetDefault("coldStartThreshold", "0s")
+
+    // 设置探测投票默认值，1表示只探测一次
+    v.SetDefault("probeVotes", 1)
+    v.SetDefault("voteThreshold", 0.6)
+
+    // 设置自动扩缩容默认值，默认不启用
+    v.SetDefault("autoscale", false)
+    v.SetDefault("autoscaleMin", 10)
+    v.SetDefault("autoscaleMax", 1000)
+    v.SetDefault("autoscaleInterval", "5s")
+    v.SetDefault("autoscaleMaxErrorRate", 0.3)
+    v.SetDefault("autoscaleMaxCPU", 0.9)
+    v.SetDefault("autoscaleMaxMemoryMB", 0)
+    v.SetDefault("autoscaleMaxGoroutines", 0)
+
+    // 设置多端口默认值，为空表示只扫描 port
+    v.SetDefault("ports", "")
+
+    // 设置扫描程序默认值
+    v.SetDefault("scanner", "zmap")
+
+    // 设置结果文件格式默认值
+    v.SetDefault("outputFormat", "csv")
+
+    // 设置结果数据库默认值，为空表示不启用
+    v.SetDefault("database", "")
+
+    // 设置检测断点默认值，为空表示不启用
+    v.SetDefault("detectCheckpointFile", "")
+
+    // 设置临时错误重试默认值，0表示不重试
+    v.SetDefault("retryCount", 0)
+    v.SetDefault("retryBackoff", "1s")
+
+    // 设置协议默认值
+    v.SetDefault("scheme", "http")
+    v.SetDefault("insecureSkipVerify", false)
+
+    // 设置认证默认值
+    v.SetDefault("authToken", "")
+    v.SetDefault("authTokensFile", "")
+
+    // 设置已加载模型探测默认值
+    v.SetDefault("probeRunning", false)
+
+    // 设置响应保存默认值
+    v.SetDefault("saveResponse", false)
+    v.SetDefault("saveResponseLength", 200)
+
+    // 设置日志默认值
+    v.SetDefault("logLevel", "info")
+    v.SetDefault("logFormat", "text")
+    v.SetDefault("logFile", "")
+
+    // 设置性能测试连接超时默认值
+    v.SetDefault("benchConnectTimeout", "10s")
+
+    // 设置指标服务默认值
+    v.SetDefault("metricsAddr", "")
+
+    // 设置关注模型通知默认值
+    v.SetDefault("webhookURL", "")
+    v.SetDefault("watchModels", []string{})
+
+    // 设置模型过滤默认值
+    v.SetDefault("includeModels", []string{})
+    v.SetDefault("excludeModels", []string{})
+
+    // 设置失败记录默认值
+    v.SetDefault("errorFile", "")
+
+    // 设置GeoIP默认值
+    v.SetDefault("geoIPDatabase", "")
+    v.SetDefault("geoIPASNDatabase", "")
+
+    // 设置嵌入性能测试默认值
+    v.SetDefault("embedOutputFile", "embeddings.csv")
+    v.SetDefault("embedRequests", 3)
+
+    // 设置性能测试接口默认值
+    v.SetDefault("benchEndpoint", "generate")
+
+    // 设置按模型选用的提示词默认值
+    v.SetDefault("benchPrompts", []ModelPrompt{})
+
+    // 设置阶段汇总默认值
+    v.SetDefault("summaryDir", "")
+
+    // 设置演练模式默认值
+    v.SetDefault("dryRun", false)
+
+    // 设置表头语言默认值
+    v.SetDefault("language", "zh")
+
+    // 设置检测限速默认值，0表示不限速
+    v.SetDefault("detectRate", 0)
+
+    // 设置代理默认值，为空表示直连
+    v.SetDefault("proxy", "")
+
+    // 设置性能测试清单默认值，为空表示测试服务检测结果中的全部模型
+    v.SetDefault("benchTargetsFile", "")
+
+    // 设置重复测试次数默认值，1表示只测一次
+    v.SetDefault("benchRepeat", 1)
+
+    // 设置其他服务识别默认值
+    v.SetDefault("probeOtherServers", false)
+
+    // 设置目标数上限默认值，0表示不限制
+    v.SetDefault("limit", 0)
+
+    // 设置打乱顺序默认值
+    v.SetDefault("shuffle", false)
+    v.SetDefault("shuffleSeed", 0)
+
+    // 设置跳过历史失败默认值
+    v.SetDefault("skipPreviousFailures", false)
+    v.SetDefault("previousResultsFile", "")
+
+    // 设置请求头默认值，userAgent 为空时使用 scan/<版本号>
+    v.SetDefault("userAgent", "")
+    v.SetDefault("headers", map[string]string{})
+}
+
+// 全部使用默认值的配置，库调用方可在此基础上修改后传给 NewScanner
+func DefaultConfig() *Config {
+    v := viper.New()
+    setDefaults(v)
+    var cfg Config
+    // 默认值都能正确解析
+    v.Unmarshal(&cfg)
+    return &cfg
+}
+
+// 配置项对应的环境变量前缀，如 SCAN_RATE、SCAN_BENCHTIMEOUT
+const envPrefix = "SCAN"
+
+// 读取配置文件，path 为空时读取当前目录的 config.yaml 且文件不存在时使用默认值，
+// 指定的文件不存在或格式错误时返回错误，环境变量 SCAN_<配置项大写> 优先于配置文件
+func LoadConfig(path string) (*Config, error) {
+    v := viper.New()
+    setDefaults(v)
+    v.SetEnvPrefix(envPrefix)
+    v.AutomaticEnv()
+    // AutomaticEnv 只对已知的配置项生效，逐个绑定，没有默认值的配置项也能被环境变量覆盖
+    fields := reflect.TypeOf(Config{})
+    for i := 0; i < fields.NumField(); i++ {
+        if key := fields.Field(i).Tag.Get("mapstructure"); key != "" {
+            v.BindEnv(key)
+        }
+    }
+    if path != "" {
+        v.SetConfigFile(path)
+    } else {
+        v.SetConfigName("config")
+        v.SetConfigType("yaml")
+        v.AddConfigPath(".")
+    }
+    if err := v.ReadInConfig(); err != nil {
+        var notFound viper.ConfigFileNotFoundError
+        if !errors.As(err, &notFound) {
+            return nil, fmt.Errorf("配置文件读取失败: %w", err)
+        }
+        slog.Warn("配置文件不存在，使用默认配置", "error", err)
+    }
+
+    var cfg Config
+    if err := v.Unmarshal(&cfg); err != nil {
+        return nil, fmt.Errorf("配置解析失败: %w", err)
+    }
+    return &cfg, nil
+}
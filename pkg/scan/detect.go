@@ -0,0 +1,404 @@
+package scan
+
+import (
+    "context"
+    "errors"
+    "fmt"
+    "io/fs"
+    "log/slog"
+    "os"
+    "strings"
+    "sync"
+    "sync/atomic"
+    "time"
+
+    "go.opentelemetry.io/otel/attribute"
+    "go.opentelemetry.io/otel/trace"
+)
+
+// 探测 hosts 中的每个目标并写入检测结果，total 为0表示目标总数未知
+// cp 不为nil时跳过断点中已探测的目标，并在结果落盘后记录新探测的目标
+func (s *Scanner) detect(ctx context.Context, manifest *stageManifest, hosts <-chan string, total int, cp *checkpoint) error {
+    // 提前退出或中断时在后台排空剩余目标，避免发送方阻塞
+    defer func() {
+        go func() {
+            for range hosts {
+            }
+        }()
+    }()
+
+    drain := newDrainer(ctx, s.cfg.DrainTimeout)
+    defer drain.stop()
+    stageCtx := ctx
+    ctx = drain.requests
+
+    s.outputFile = s.cfg.OllamaOutputFile
+    
+    // 从断点继续时追加到已有结果，否则创建新文件并写入表头，检测完成前写入 .partial 文件
+    resumed := cp.count() > 0
+    file, err := openPartial(s.outputFile, resumed, s.fileMode)
+    if err != nil {
+        return fmt.Errorf("创建CSV文件失败: %w", err)
+    }
+    s.csvFile = file
+    s.writer, err = s.newResultWriter(file, detectHeader(s.cfg))
+    if err != nil {
+        file.close(false)
+        s.csvFile = nil
+        return fmt.Errorf("写入检测表头失败: %w", err)
+    }
+    s.writer, s.sharedFile, err = s.withRedactedCopy(s.writer, s.outputFile, resumed, detectHeader(s.cfg))
+    if err != nil {
+        file.close(false)
+        s.csvFile = nil
+        return err
+    }
+    
+    defer s.Close()
+    errorLog, err := s.openProbeErrorLog(resumed)
+    if err != nil {
+        return err
+    }
+    defer func() {
+        if err := errorLog.close(); err != nil {
+            slog.Warn("写入失败记录文件失败", "error", err)
+        }
+    }()
+    s.sinks = s.newSinks()
+    s.watch = newWatchNotifier(s.cfg.WebhookURL, s.cfg.WatchModels)
+    if s.store, err = s.openStore(); err != nil {
+        return err
+    }
+
+    // 流水线模式下检测结果经有界缓冲直接交给性能测试，
+    // 性能测试跟不上时缓冲写满，检测协程阻塞形成背压，内存占用不会无限增长
+    var pipeline chan benchTarget
+    var pipelineDone chan error
+    if s.cfg.Pipeline {
+        benchManifest := newStageManifest("benchmark",
+            []string{s.cfg.OllamaOutputFile},
+            []string{s.cfg.OutputFile})
+        s.dash.watch(benchManifest)
+        run, err := s.newBenchRun(stageCtx, benchManifest, s.cfg.ScanOutputFile)
+        if err != nil {
+            return err
+        }
+        pipeline = make(chan benchTarget, s.cfg.PipelineBuffer)
+        pipelineDone = make(chan error, 1)
+        go func() {
+            for target := range pipeline {
+                run.submit(target.ip, target.port, target.model)
+            }
+            err := run.finish()
+            s.writeManifest(benchManifest, err)
+            pipelineDone <- err
+        }()
+    }
+
+    workerPool := newWorkerPool(s.initialWorkers(), drain.dispatch.Done())
+    scaler := s.startAutoscaler("服务检测", workerPool)
+    var wg sync.WaitGroup
+    var writeMu sync.Mutex
+    var skipped int64
+    failures := newFailureStats()
+    summary := newStageSummary("detect")
+    catalog := newModelCatalog(s.cfg.CatalogFile, s.cfg.CatalogExamples)
+    
+    // 初始化进度条，流式输入时总数未知只显示计数和速率，设置了 limit 时总数不超过上限
+    if s.cfg.Limit > 0 && (total == 0 || total > s.cfg.Limit) {
+        total = s.cfg.Limit
+    }
+    s.progress = s.newProgress("扫描进度", total)
+    s.progress.start()
+
+    // 定期落盘断点，先刷新结果再写断点，保证断点中的目标都已写入结果文件
+    saveCheckpoint := func() {
+        writeMu.Lock()
+        defer writeMu.Unlock()
+        s.writer.flush()
+        if err := cp.save(); err != nil {
+            slog.Warn("写入检测断点失败", "error", err)
+        }
+    }
+    stopCheckpoint := make(chan struct{})
+    checkpointDone := make(chan struct{})
+    go func() {
+        defer close(checkpointDone)
+        if cp == nil {
+            return
+        }
+        ticker := time.NewTicker(s.cfg.CheckpointInterval)
+        defer ticker.Stop()
+        for {
+            select {
+            case <-ticker.C:
+                saveCheckpoint()
+            case <-stopCheckpoint:
+                return
+            }
+        }
+    }()
+
+    queued := 0
+dispatch:
+    for {
+        var target string
+        select {
+        case next, ok := <-hosts:
+            if !ok {
+                break dispatch
+            }
+            target = strings.TrimSpace(next)
+        case <-drain.dispatch.Done():
+            break dispatch
+        }
+        if target == "" {
+            continue
+        }
+        // 断点中已探测的目标也计入上限，续测时处理的仍是同样的前N个目标
+        if s.cfg.Limit > 0 && queued >= s.cfg.Limit {
+            slog.Info("已达到目标数上限，停止派发", "limit", s.cfg.Limit)
+            break dispatch
+        }
+        queued++
+        // 跳过断点中已探测的目标
+        if cp.has(target) {
+            s.progress.increment()
+            s.health.touch()
+            manifest.add("resumed", 1)
+            continue
+        }
+        ip, port := splitTarget(target, s.defaultPort())
+
+        if !workerPool.acquire(drain.dispatch.Done()) {
+            break dispatch
+        }
+        manifest.add("targets", 1)
+        wg.Add(1)
+        
+        go func(target, ip string, port int) {
+            defer func() {
+                workerPool.release()
+                wg.Done()
+                s.progress.increment()
+                s.health.touch()
+            }()
+
+            if !s.breaker.allow(ip) {
+                atomic.AddInt64(&skipped, 1)
+                manifest.add("skipped", 1)
+                return
+            }
+            // 按 detectRate 限制全局探测速率，中断时不再探测
+            if err := s.waitDetectRate(ctx); err != nil {
+                return
+            }
+
+            _, probeSpan := s.tracer.Start(ctx, "probe",
+                trace.WithAttributes(attribute.String("ip", ip)))
+            models, headers, err := s.voteModels(ctx, ip, port)
+            // 模型列表接口不可用时尝试识别 vLLM、LM Studio 等其他服务，受限和令牌被拒绝的目标不再探测
+            var service string
+            var se *statusError
+            if s.cfg.ProbeOtherServers && len(models) == 0 &&
+                (err == nil || errors.Is(err, errDecode) ||
+                    errors.As(err, &se) && restrictedStatus(err, s.cfg.RestrictedStatuses) == 0 && !s.authRejected(ip, port, err)) {
+                other, otherModels := s.probeOtherServer(ctx, ip, port)
+                if len(otherModels) > 0 {
+                    models, err = otherModels, nil
+                }
+                if other != "" {
+                    service = other
+                    manifest.add("other_servers", 1)
+                }
+            }
+            if service == "" && len(models) > 0 {
+                service = serviceOllama
+            }
+            models = s.filterModels(models)
+            probeSpan.SetAttributes(attribute.Int("models", len(models)))
+            endSpan(probeSpan, err)
+            s.metrics.probe()
+            summary.probe(modelNames(models))
+            decodeFailed := errors.Is(err, errDecode)
+            if err != nil {
+                failures.add(classifyError(err))
+                manifest.add("errors", 1)
+                slog.Debug("探测失败", "ip", ip, "port", port, "reason", classifyError(err), "error", err)
+                errorLog.write(ip, port, err)
+            }
+            scaler.observe(err != nil && classifyError(err) == "超时")
+            if s.breaker.record(ip, reachable(err)) {
+                slog.Warn("子网连续连接失败，暂停探测",
+                    "subnet", subnetKey(ip),
+                    "failures", s.cfg.BreakerThreshold,
+                    "cooldown", s.cfg.BreakerCooldown)
+            }
+            if len(models) > 0 {
+                slog.Info("发现可用服务",
+                    "ip", ip,
+                    "port", port,
+                    "models", modelNames(models))
+                manifest.add("services", 1)
+                s.metrics.found()
+                manifest.add("models", len(models))
+                for _, model := range models {
+                    // 模型目录是分享用的汇总，示例地址与其他输出一样脱敏
+                    catalog.add(model.Name, hostPort(s.redactIP(ip), port))
+                    s.watch.notify(s.redactIP(ip), port, model.Name)
+                }
+            }
+            if decodeFailed {
+                manifest.add("decode_failures", 1)
+            }
+            // 已加载的模型测试速度明显快于冷启动，记录下来便于区分测试结果
+            running := make(map[string]RunningModel)
+            if s.cfg.ProbeRunning && len(models) > 0 && service == serviceOllama {
+                if loaded, err := s.getRunningModels(ctx, ip, port); err == nil {
+                    for _, m := range loaded {
+                        running[m.Name] = m
+                    }
+                    if len(loaded) > 0 {
+                        slog.Info("已加载模型", "ip", ip, "port", port, "count", len(loaded))
+                        manifest.add("running_models", len(loaded))
+                    }
+                }
+            }
+            // 逐个模型确认是否支持向量嵌入
+            results := make([]DetectResult, len(models))
+            for i, model := range models {
+                results[i] = DetectResult{
+                    IP:                ip,
+                    Port:              port,
+                    Model:             model.Name,
+                    Status:            "成功",
+                    Size:              model.Size,
+                    ParameterSize:     model.ParameterSize,
+                    QuantizationLevel: model.QuantizationLevel,
+                    ModifiedAt:        model.ModifiedAt,
+                    Headers:           headers,
+                    ServiceType:       service,
+                }
+                if m, ok := running[model.Name]; ok {
+                    results[i].Loaded = true
+                    results[i].SizeVRAM = m.SizeVRAM
+                }
+                if s.cfg.ProbeEmbeddings && service == serviceOllama {
+                    if dim, err := s.probeEmbedding(ctx, ip, port, model.Name); err == nil && dim > 0 {
+                        results[i].EmbeddingDim = dim
+                        manifest.add("embedding_models", 1)
+                    }
+                }
+            }
+
+            // 认证或限流等状态说明服务存在但受保护，单独记录
+            restricted := restrictedStatus(err, s.cfg.RestrictedStatuses)
+            if s.authRejected(ip, port, err) {
+                // 已配置令牌仍返回401，说明令牌无效而非服务不存在
+                slog.Warn("令牌被拒绝", "ip", ip, "port", port, "status", 401)
+                manifest.add("auth_rejected", 1)
+            } else if restricted > 0 {
+                slog.Info("发现受限服务", "ip", ip, "port", port, "status", restricted)
+                manifest.add("restricted", 1)
+            }
+
+            // HTTP探测失败时确认端口是否开放，并记录开放端口上的横幅
+            var banner string
+            var portOpen bool
+            if s.cfg.BannerGrab && err != nil && !decodeFailed && restricted == 0 {
+                banner, portOpen = s.grabBanner(ctx, ip, port)
+                if portOpen {
+                    manifest.add("banners", 1)
+                }
+            }
+
+            if pipeline != nil {
+                for _, result := range results {
+                    if s.benchable(result.ServiceType) {
+                        pipeline <- benchTarget{ip: ip, port: port, model: result.Model, service: result.ServiceType}
+                    }
+                }
+            }
+
+            writeMu.Lock()
+            defer writeMu.Unlock()
+
+            // 记录检测时确认的协议，性能测试沿用
+            scheme := s.schemeFor(ip, port)
+            country, asn, org := s.geo.lookup(ip)
+            record := func(result DetectResult) {
+                result.Scheme = scheme
+                result.Country, result.ASN, result.ASOrg = country, asn, org
+                s.writer.write(result)
+                s.publishDetect(result)
+            }
+            if len(results) > 0 {
+                for _, result := range results {
+                    record(result)
+                }
+            } else if decodeFailed {
+                // 响应无法解析时记录下来，避免误判为无模型
+                slog.Warn("模型列表解析失败", "ip", ip, "port", port, "error", err)
+                record(DetectResult{IP: ip, Port: port, Status: "解析失败", Headers: headers})
+            } else if restricted > 0 {
+                record(DetectResult{IP: ip, Port: port, Status: "受限", Headers: headers, HTTPStatus: restricted})
+            } else if portOpen || service == serviceUnknown {
+                record(DetectResult{IP: ip, Port: port, Status: "非Ollama", Headers: headers, Banner: banner, ServiceType: service})
+            }
+            s.writer.flush()
+            // 请求被强制取消时结果不完整，续测时重新探测
+            if ctx.Err() == nil {
+                cp.mark(target)
+            }
+        }(target, ip, port)
+    }
+    
+    drain.wait(&wg)
+    scaler.close()
+    close(stopCheckpoint)
+    <-checkpointDone
+    if cp != nil {
+        // 全部完成后删除断点，下次检测从头开始
+        if drain.interrupted.Load() {
+            saveCheckpoint()
+        } else if err := os.Remove(cp.path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+            slog.Warn("删除检测断点失败", "error", err)
+        }
+    }
+    // 中断时结果留在 .partial 文件中，续测后再重命名
+    if !drain.interrupted.Load() {
+        if err := s.finishOutput(); err != nil {
+            return err
+        }
+    }
+    s.progress.finish()
+    if skipped > 0 {
+        slog.Warn("子网熔断跳过IP", "count", skipped)
+    }
+    s.reportFailures("detect", failures)
+    s.reportSummary(summary, failures)
+    if err := catalog.write(s.cfg.CatalogFile, s.fileMode, s.cfg.Language); err != nil {
+        slog.Warn("生成模型目录失败", "error", err)
+    }
+
+    if pipeline != nil {
+        close(pipeline)
+        slog.Info("等待流水线性能测试完成")
+        if err := <-pipelineDone; err != nil {
+            return fmt.Errorf("流水线性能测试失败: %w", err)
+        }
+    }
+    if drain.interrupted.Load() {
+        return errInterrupted
+    }
+    return nil
+}
+
+// 发送检测结果到外部输出，IP按配置脱敏
+// 检测结果文件是性能测试的输入，与结果库一样保留真实IP
+func (s *Scanner) publishDetect(result DetectResult) {
+    s.store.recordDetect(result)
+    s.dash.detected(result)
+    result.IP = s.redactIP(result.IP)
+    s.sinks.publish("detect", result.IP, result)
+}
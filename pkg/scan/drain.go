@@ -0,0 +1,94 @@
+package scan
+
+import (
+    "context"
+    "errors"
+    "log/slog"
+    "os"
+    "os/signal"
+    "sync"
+    "sync/atomic"
+    "syscall"
+    "time"
+)
+
+// 收到退出信号后任务被中断
+var errInterrupted = errors.New("收到退出信号，任务已中断")
+
+// 优雅退出控制：阶段上下文被取消（收到退出信号）后立即停止派发新任务，
+// 在途任务在 drainTimeout 内完成并落盘，超时后取消剩余请求
+type drainer struct {
+    timeout        time.Duration
+    dispatch       context.Context // 阶段上下文取消即取消，用于停止派发
+    requests       context.Context // 排空超时后取消，用于在途请求
+    cancelDispatch context.CancelFunc
+    cancelRequests context.CancelFunc
+    signals        chan os.Signal
+    interrupted    atomic.Bool
+}
+
+// 创建退出控制，ctx 为阶段上下文
+// 在途请求使用不随 ctx 取消的上下文，保证收到信号后仍能在排空时间内完成
+func newDrainer(ctx context.Context, timeout time.Duration) *drainer {
+    d := &drainer{timeout: timeout, signals: make(chan os.Signal, 1)}
+    d.requests, d.cancelRequests = context.WithCancel(context.WithoutCancel(ctx))
+    d.dispatch, d.cancelDispatch = context.WithCancel(ctx)
+
+    go func() {
+        select {
+        case <-ctx.Done():
+        case <-d.requests.Done():
+            return
+        }
+        slog.Info("收到退出信号，停止派发新任务", "timeout", d.timeout)
+        d.interrupted.Store(true)
+        d.cancelDispatch()
+
+        // 再次收到信号时不再等待
+        signal.Notify(d.signals, os.Interrupt, syscall.SIGTERM)
+        select {
+        case <-d.signals:
+            slog.Warn("再次收到退出信号，立即取消剩余请求")
+            d.cancelRequests()
+        case <-d.requests.Done():
+        }
+    }()
+    return d
+}
+
+// 派发是否已停止
+func (d *drainer) stopped() bool {
+    return d.dispatch.Err() != nil
+}
+
+// 等待在途任务完成，派发停止后最多等待 timeout，超时则取消剩余请求
+func (d *drainer) wait(wg *sync.WaitGroup) {
+    done := make(chan struct{})
+    go func() {
+        wg.Wait()
+        close(done)
+    }()
+
+    select {
+    case <-done:
+        return
+    case <-d.dispatch.Done():
+    }
+
+    timer := time.NewTimer(d.timeout)
+    defer timer.Stop()
+    select {
+    case <-done:
+    case <-timer.C:
+        slog.Warn("等待在途任务超时，取消剩余请求", "timeout", d.timeout)
+        d.cancelRequests()
+        <-done
+    }
+}
+
+// 停止监听信号并释放上下文
+func (d *drainer) stop() {
+    signal.Stop(d.signals)
+    d.cancelRequests()
+    d.cancelDispatch()
+}
@@ -0,0 +1,91 @@
+package scan
+
+import (
+    "context"
+    "fmt"
+    "log/slog"
+    "time"
+)
+
+// 演练模式下列出的目标数
+const dryRunTargets = 5
+
+// 演练扫描，只打印将要执行的扫描命令，不发送任何数据包
+func (s *Scanner) dryRunScan(ctx context.Context) error {
+    if err := s.checkScanner(); err != nil {
+        slog.Warn("演练模式", "error", err)
+    }
+    if s.cfg.InputPreprocessor != "" {
+        slog.Info("演练模式：输入文件将先经过预处理命令", "command", s.cfg.InputPreprocessor)
+    }
+    if s.cfg.Scanner == scannerNative {
+        rate, _ := s.scheduledRate(time.Now())
+        slog.Info("演练模式：原生扫描",
+            "input", s.cfg.InputFile,
+            "ports", s.portSpec(),
+            "rate", rate,
+            "workers", s.cfg.MaxWorkers,
+            "output", s.cfg.ScanOutputFile)
+    } else {
+        // 构建命令时会打印完整命令
+        s.scanCommand(ctx, s.cfg.InputFile, s.cfg.ScanOutputFile)
+    }
+    slog.Info("演练模式，未执行扫描")
+    return nil
+}
+
+// 演练检测，打印前几个将要请求的地址和目标总数，不发送请求
+func (s *Scanner) dryRunDetect(ctx context.Context) error {
+    targets, err := s.openTargets(s.cfg.ScanOutputFile)
+    if err != nil {
+        return fmt.Errorf("读取IP文件失败: %w", err)
+    }
+    defer targets.Close()
+
+    hosts := make(chan string)
+    streamCtx, stopStream := context.WithCancel(ctx)
+    defer stopStream()
+    go func() {
+        defer close(hosts)
+        s.streamReader(streamCtx, targets, hosts)
+    }()
+    shown := 0
+    for target := range hosts {
+        ip, port := splitTarget(target, s.defaultPort())
+        slog.Info("演练模式：将请求", "url", s.serviceURL(ip, port, "/api/tags"))
+        if shown++; shown == dryRunTargets {
+            break
+        }
+    }
+    stopStream()
+
+    if s.cfg.ScanOutputFile != stdinPath && s.cfg.InputPreprocessor == "" {
+        if file, err := openRead(s.cfg.ScanOutputFile); err == nil {
+            total, _ := s.countReader(file)
+            file.Close()
+            slog.Info("演练模式：目标总数", "count", total)
+        }
+    }
+    slog.Info("演练模式，未执行检测")
+    return nil
+}
+
+// 演练性能测试，打印前几个将要测试的模型和请求地址，不发送请求
+func (s *Scanner) dryRunBench() error {
+    targets, err := s.loadBenchTargets()
+    if err != nil {
+        return err
+    }
+    for i, target := range targets {
+        if i == dryRunTargets {
+            break
+        }
+        path, _ := s.benchRequest(target.model, s.promptFor(target.model))
+        slog.Info("演练模式：将测试",
+            "url", s.serviceURL(target.ip, target.port, path),
+            "model", target.model)
+    }
+    slog.Info("演练模式：测试总数", "count", len(targets))
+    slog.Info("演练模式，未执行性能测试")
+    return nil
+}
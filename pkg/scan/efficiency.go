@@ -0,0 +1,129 @@
+package scan
+
+import (
+    "encoding/csv"
+    "errors"
+    "fmt"
+    "log/slog"
+    "sort"
+    "strconv"
+)
+
+// 效率得分权重
+type EfficiencyWeights struct {
+    Throughput float64 `mapstructure:"throughput"`
+    Latency    float64 `mapstructure:"latency"`
+}
+
+// 单台主机的每小时成本
+type HostCost struct {
+    IP          string  `mapstructure:"ip"`
+    CostPerHour float64 `mapstructure:"costPerHour"`
+}
+
+// 一条参与评分的测试结果
+type efficiencyRow struct {
+    result BenchResult
+    score  float64
+}
+
+// 测试结束后读取结果文件计算效率得分，按得分从高到低写入 efficiencyFile
+// 吞吐和延迟分别相对本次最优值归一化后按权重加权，满分100
+func (s *Scanner) writeEfficiency(results string) error {
+    weights := s.cfg.EfficiencyWeights
+    if weights.Throughput+weights.Latency <= 0 {
+        return errors.New("效率权重之和必须大于0")
+    }
+
+    rows, err := s.readEfficiencyRows(results)
+    if err != nil {
+        return fmt.Errorf("读取测试结果失败: %w", err)
+    }
+    if len(rows) == 0 {
+        return nil
+    }
+
+    var maxTps, minLatency float64
+    for _, row := range rows {
+        tps, latency := row.result.TokensPerSec, float64(row.result.FirstTokenMs)
+        if tps > maxTps {
+            maxTps = tps
+        }
+        if latency > 0 && (minLatency == 0 || latency < minLatency) {
+            minLatency = latency
+        }
+    }
+    for i := range rows {
+        var tpsScore, latencyScore float64
+        if maxTps > 0 {
+            tpsScore = rows[i].result.TokensPerSec / maxTps
+        }
+        if latency := float64(rows[i].result.FirstTokenMs); latency > 0 {
+            latencyScore = minLatency / latency
+        }
+        rows[i].score = 100 * (weights.Throughput*tpsScore + weights.Latency*latencyScore) /
+            (weights.Throughput + weights.Latency)
+    }
+    sort.SliceStable(rows, func(i, j int) bool {
+        return rows[i].score > rows[j].score
+    })
+
+    costs := make(map[string]float64, len(s.cfg.HostCosts))
+    for _, c := range s.cfg.HostCosts {
+        costs[c.IP] = c.CostPerHour
+    }
+
+    file, err := createFile(s.cfg.EfficiencyFile, s.fileMode)
+    if err != nil {
+        return fmt.Errorf("创建效率文件失败: %w", err)
+    }
+    defer file.Close()
+
+    writer := csv.NewWriter(file)
+    writer.Write(localizeHeader(s.cfg.Language,
+        []string{"IP地址", "端口", "模型名称", "首Token延迟(ms)", "Tokens/s", "效率得分", "每美元Tokens"}))
+    for _, row := range rows {
+        r := row.result
+        // 每美元Tokens = 每小时生成的Token数 / 每小时成本，未配置成本时留空
+        // 成本按真实IP查找，写出的排名与其他分享用输出一样脱敏
+        perDollar := ""
+        cost, ok := costs[r.IP]
+        if !ok {
+            cost = s.cfg.DefaultCostPerHour
+        }
+        if cost > 0 {
+            perDollar = fmt.Sprintf("%.0f", r.TokensPerSec*3600/cost)
+        }
+        writer.Write([]string{
+            s.redactIP(r.IP),
+            strconv.Itoa(r.Port),
+            r.Model,
+            strconv.FormatInt(r.FirstTokenMs, 10),
+            fmt.Sprintf("%.2f", r.TokensPerSec),
+            fmt.Sprintf("%.1f", row.score),
+            perDollar,
+        })
+    }
+    writer.Flush()
+    if err := writer.Error(); err != nil {
+        return fmt.Errorf("写入效率文件失败: %w", err)
+    }
+    slog.Info("效率排名已保存", "path", s.cfg.EfficiencyFile)
+    return nil
+}
+
+// 读取结果文件中测试成功且有生成速度的记录，CSV 和 JSONL 格式都能读取
+func (s *Scanner) readEfficiencyRows(path string) ([]efficiencyRow, error) {
+    results, err := readPriorBench(path, s.cfg.OutputFormat)
+    if err != nil {
+        return nil, err
+    }
+    var rows []efficiencyRow
+    for _, result := range results {
+        if result.Status != "成功" || result.TokensPerSec <= 0 {
+            continue
+        }
+        rows = append(rows, efficiencyRow{result: result})
+    }
+    return rows, nil
+}
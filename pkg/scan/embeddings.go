@@ -0,0 +1,240 @@
+package scan
+
+import (
+    "bytes"
+    "context"
+    "encoding/json"
+    "fmt"
+    "log/slog"
+    "net/http"
+    "strconv"
+    "strings"
+    "sync"
+    "time"
+)
+
+// 嵌入探测使用的输入文本
+const embeddingProbeInput = "hello"
+
+// 请求 /api/embeddings 确认模型是否支持向量嵌入，返回向量维度
+func (s *Scanner) probeEmbedding(ctx context.Context, ip string, port int, model string) (int, error) {
+    return s.embed(ctx, s.httpClient, ip, port, model)
+}
+
+// 使用指定客户端请求一次 /api/embeddings，返回向量维度
+func (s *Scanner) embed(ctx context.Context, client *http.Client, ip string, port int, model string) (int, error) {
+    if err := s.inflight.acquire(ctx); err != nil {
+        return 0, err
+    }
+    defer s.inflight.release()
+
+    body, _ := json.Marshal(map[string]interface{}{
+        "model":  model,
+        "prompt": embeddingProbeInput,
+    })
+    req, err := http.NewRequestWithContext(ctx, "POST",
+        s.serviceURL(ip, port, "/api/embeddings"),
+        bytes.NewReader(body))
+    if err != nil {
+        return 0, err
+    }
+    req.Header.Set("Content-Type", "application/json")
+    s.authorize(req, ip, port)
+    resp, err := client.Do(req)
+    if err != nil {
+        return 0, err
+    }
+    defer resp.Body.Close()
+    if resp.StatusCode != http.StatusOK {
+        return 0, &statusError{code: resp.StatusCode}
+    }
+
+    var data struct {
+        Embedding []float64 `json:"embedding"`
+    }
+    if err := json.NewDecoder(resp.Body).Decode(&data); err != nil {
+        return 0, err
+    }
+    return len(data.Embedding), nil
+}
+
+// 是否为嵌入模型，按模型家族（bert 系列）或名称中的 embed 判断
+func (m ModelInfo) embeddingModel() bool {
+    return strings.Contains(strings.ToLower(m.Family), "bert") ||
+        strings.Contains(strings.ToLower(m.Name), "embed")
+}
+
+// 嵌入性能测试结果
+type EmbedResult struct {
+    IP        string `json:"ip"`
+    Port      int    `json:"port"`
+    Model     string `json:"model"`
+    Status    string `json:"status"`
+    Dimension int    `json:"dimension"`
+    LatencyMs int64  `json:"latency_ms"`
+}
+
+// 嵌入性能测试结果表头，与 csvRecord 的列一一对应
+func embedHeader() []string {
+    return []string{"IP地址", "端口", "模型名称", "状态", "向量维度", "平均延迟(ms)"}
+}
+
+// 转换为CSV记录
+func (r EmbedResult) csvRecord(cfg *Config) []string {
+    return []string{
+        r.IP,
+        strconv.Itoa(r.Port),
+        r.Model,
+        r.Status,
+        strconv.Itoa(r.Dimension),
+        strconv.FormatInt(r.LatencyMs, 10),
+    }
+}
+
+// 嵌入性能测试，只测试检测结果中的嵌入模型，/api/generate 无法测试这类模型
+// 每个服务先重新获取模型列表识别嵌入模型，再对每个模型请求 /api/embeddings，记录向量维度和平均延迟
+func (s *Scanner) BenchmarkEmbeddings(ctx context.Context) (err error) {
+    ctx, span := s.tracer.Start(ctx, "embeddings")
+    defer func() { endSpan(span, err) }()
+
+    manifest := newStageManifest("embeddings",
+        []string{s.cfg.OllamaOutputFile},
+        []string{s.cfg.EmbedOutputFile})
+    s.dash.watch(manifest)
+    defer func() { s.writeManifest(manifest, err) }()
+
+    targets, err := s.readBenchTargets(s.cfg.OllamaOutputFile)
+    if err != nil {
+        return fmt.Errorf("读取服务检测结果失败: %w", err)
+    }
+
+    // 按服务分组，每个服务只获取一次模型列表
+    var hosts []string
+    models := make(map[string][]benchTarget)
+    for _, target := range targets {
+        // 嵌入接口只有 Ollama 提供
+        if target.service != "" && target.service != serviceOllama {
+            continue
+        }
+        key := hostPort(target.ip, target.port)
+        if _, ok := models[key]; !ok {
+            hosts = append(hosts, key)
+        }
+        models[key] = append(models[key], target)
+    }
+
+    defer s.Close()
+    file, err := openPartial(s.cfg.EmbedOutputFile, false, s.fileMode)
+    if err != nil {
+        return fmt.Errorf("创建CSV文件失败: %w", err)
+    }
+    s.csvFile = file
+    s.writer, err = s.newResultWriter(file, embedHeader())
+    if err != nil {
+        return fmt.Errorf("写入嵌入测试表头失败: %w", err)
+    }
+    s.writer, s.sharedFile, err = s.withRedactedCopy(s.writer, s.cfg.EmbedOutputFile, false, embedHeader())
+    if err != nil {
+        return err
+    }
+
+    s.health.setStage("embeddings", len(hosts))
+    defer s.health.setStage("idle", 0)
+    s.progress = s.newProgress("嵌入测试进度", len(hosts))
+    s.progress.start()
+
+    var wg sync.WaitGroup
+    var writeMu sync.Mutex
+    sem := make(chan struct{}, s.cfg.MaxWorkers)
+    for _, key := range hosts {
+        if ctx.Err() != nil {
+            break
+        }
+        sem <- struct{}{}
+        wg.Add(1)
+        go func(targets []benchTarget) {
+            defer func() {
+                <-sem
+                wg.Done()
+                s.progress.increment()
+                s.health.advance(1)
+            }()
+            for _, result := range s.benchHostEmbeddings(ctx, targets) {
+                writeMu.Lock()
+                if err := s.writer.write(result); err != nil {
+                    slog.Warn("写入嵌入测试结果失败", "error", err)
+                }
+                writeMu.Unlock()
+            }
+        }(models[key])
+    }
+    wg.Wait()
+
+    if ctx.Err() != nil {
+        return errInterrupted
+    }
+    return s.finishOutput()
+}
+
+// 测试一个服务上的嵌入模型，模型列表获取失败时跳过该服务
+func (s *Scanner) benchHostEmbeddings(ctx context.Context, targets []benchTarget) []EmbedResult {
+    ip, port := targets[0].ip, targets[0].port
+    infos, _, err := s.getModels(ctx, ip, port)
+    if err != nil {
+        slog.Warn("获取模型列表失败", "ip", ip, "port", port, "error", err)
+        return nil
+    }
+    embedding := make(map[string]bool)
+    for _, info := range infos {
+        if info.embeddingModel() {
+            embedding[info.Name] = true
+        }
+    }
+
+    var results []EmbedResult
+    for _, target := range targets {
+        if embedding[target.model] && ctx.Err() == nil {
+            results = append(results, s.benchEmbedding(ctx, ip, port, target.model))
+        }
+    }
+    return results
+}
+
+// 先请求一次加载模型并取得向量维度，再计时请求 embedRequests 次，记录平均延迟
+func (s *Scanner) benchEmbedding(ctx context.Context, ip string, port int, model string) EmbedResult {
+    result := EmbedResult{IP: ip, Port: port, Model: model}
+    request := func() (int, error) {
+        reqCtx, cancel := context.WithTimeout(ctx, s.cfg.BenchTimeout)
+        defer cancel()
+        return s.embed(reqCtx, s.benchClient, ip, port, model)
+    }
+    fail := func(err error) EmbedResult {
+        result.Status = "请求失败"
+        if s.authRejected(ip, port, err) {
+            result.Status = "令牌被拒绝"
+        }
+        return result
+    }
+
+    dim, err := request()
+    if err != nil {
+        return fail(err)
+    }
+    if dim == 0 {
+        result.Status = "无向量"
+        return result
+    }
+    result.Dimension = dim
+
+    var total time.Duration
+    for i := 0; i < s.cfg.EmbedRequests; i++ {
+        start := time.Now()
+        if _, err := request(); err != nil {
+            return fail(err)
+        }
+        total += time.Since(start)
+    }
+    result.Status = "成功"
+    result.LatencyMs = total.Milliseconds() / int64(s.cfg.EmbedRequests)
+    return result
+}
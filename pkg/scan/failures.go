@@ -0,0 +1,143 @@
+package scan
+
+import (
+    "encoding/csv"
+    "errors"
+    "fmt"
+    "log/slog"
+    "net"
+    "os"
+    "sort"
+    "strconv"
+    "sync"
+    "syscall"
+)
+
+// 非200响应
+type statusError struct {
+    code int
+}
+
+func (e *statusError) Error() string {
+    return fmt.Sprintf("HTTP %d", e.code)
+}
+
+// 返回受限状态码，响应状态不在 statuses 中时返回0
+func restrictedStatus(err error, statuses []int) int {
+    var se *statusError
+    if !errors.As(err, &se) {
+        return 0
+    }
+    for _, code := range statuses {
+        if se.code == code {
+            return code
+        }
+    }
+    return 0
+}
+
+// 判断目标是否有响应，非200、解析失败和投票未通过说明主机可达
+func reachable(err error) bool {
+    var se *statusError
+    return err == nil || errors.Is(err, errDecode) || errors.Is(err, errNoConsensus) || errors.As(err, &se)
+}
+
+// 将错误归类为失败原因
+func classifyError(err error) string {
+    var se *statusError
+    var netErr net.Error
+    switch {
+    case errors.As(err, &se):
+        return se.Error()
+    case errors.Is(err, errDecode):
+        return "解析失败"
+    case errors.Is(err, errNoConsensus):
+        return "投票未通过"
+    case errors.Is(err, syscall.ECONNREFUSED):
+        return "连接被拒绝"
+    case errors.Is(err, syscall.ECONNRESET):
+        return "连接被重置"
+    case errors.Is(err, syscall.EHOSTUNREACH), errors.Is(err, syscall.ENETUNREACH):
+        return "网络不可达"
+    case errors.As(err, &netErr) && netErr.Timeout():
+        return "超时"
+    default:
+        return "其他错误"
+    }
+}
+
+// 失败原因统计
+type failureStats struct {
+    mu      sync.Mutex
+    reasons map[string]int
+}
+
+func newFailureStats() *failureStats {
+    return &failureStats{reasons: make(map[string]int)}
+}
+
+// 记录一次失败
+func (f *failureStats) add(reason string) {
+    f.mu.Lock()
+    defer f.mu.Unlock()
+    f.reasons[reason]++
+}
+
+// 按数量从多到少排列的失败原因
+func (f *failureStats) sorted() []string {
+    f.mu.Lock()
+    defer f.mu.Unlock()
+    reasons := make([]string, 0, len(f.reasons))
+    for reason := range f.reasons {
+        reasons = append(reasons, reason)
+    }
+    sort.Slice(reasons, func(i, j int) bool {
+        if f.reasons[reasons[i]] != f.reasons[reasons[j]] {
+            return f.reasons[reasons[i]] > f.reasons[reasons[j]]
+        }
+        return reasons[i] < reasons[j]
+    })
+    return reasons
+}
+
+// 打印失败原因统计，并按配置写入汇总文件
+func (s *Scanner) reportFailures(stage string, f *failureStats) {
+    reasons := f.sorted()
+    if len(reasons) == 0 {
+        return
+    }
+
+    for _, reason := range reasons {
+        slog.Info("失败原因统计", "stage", stage, "reason", reason, "count", f.reasons[reason])
+    }
+
+    if s.cfg.FailureSummaryFile == "" {
+        return
+    }
+    // 每次运行的第一个阶段覆盖上次的汇总并写表头，之后的阶段各追加一段，检测和测试的统计都保留
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    flag := os.O_CREATE | os.O_WRONLY | os.O_TRUNC
+    if s.failureSummaryStarted {
+        flag = os.O_CREATE | os.O_WRONLY | os.O_APPEND
+    }
+    file, err := openFile(s.cfg.FailureSummaryFile, flag, s.fileMode)
+    if err != nil {
+        slog.Warn("创建失败汇总文件失败", "error", err)
+        return
+    }
+    defer file.Close()
+
+    writer := csv.NewWriter(file)
+    if !s.failureSummaryStarted {
+        writer.Write(localizeHeader(s.cfg.Language, []string{"阶段", "失败原因", "数量"}))
+        s.failureSummaryStarted = true
+    }
+    for _, reason := range reasons {
+        writer.Write([]string{stage, reason, strconv.Itoa(f.reasons[reason])})
+    }
+    writer.Flush()
+    if err := writer.Error(); err != nil {
+        slog.Warn("写入失败汇总文件失败", "error", err)
+    }
+}
@@ -0,0 +1,20 @@
+//go:build !unix
+
+package scan
+
+import "time"
+
+// 非类Unix系统无法读取上限，使用默认值
+func fdLimit() int {
+    return defaultFDLimit
+}
+
+// 非类Unix系统无法统计打开的描述符
+func openFDs() int {
+    return -1
+}
+
+// 非类Unix系统无法读取CPU时间，返回0表示未知
+func cpuTime() time.Duration {
+    return 0
+}
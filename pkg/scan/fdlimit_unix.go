@@ -0,0 +1,39 @@
+//go:build unix
+
+package scan
+
+import (
+    "os"
+    "syscall"
+    "time"
+)
+
+// 读取当前进程的文件描述符软上限
+func fdLimit() int {
+    var rlimit syscall.Rlimit
+    if err := syscall.Getrlimit(syscall.RLIMIT_NOFILE, &rlimit); err != nil {
+        return defaultFDLimit
+    }
+    if rlimit.Cur > 1<<20 {
+        return 1 << 20
+    }
+    return int(rlimit.Cur)
+}
+
+// 统计当前打开的文件描述符数量，无法读取时返回-1
+func openFDs() int {
+    entries, err := os.ReadDir("/dev/fd")
+    if err != nil {
+        return -1
+    }
+    return len(entries)
+}
+
+// 读取进程累计使用的CPU时间
+func cpuTime() time.Duration {
+    var usage syscall.Rusage
+    if err := syscall.Getrusage(syscall.RUSAGE_SELF, &usage); err != nil {
+        return 0
+    }
+    return time.Duration(usage.Utime.Nano() + usage.Stime.Nano())
+}
@@ -0,0 +1,59 @@
+package scan
+
+import (
+    "fmt"
+    "net"
+
+    "github.com/oschwald/geoip2-golang"
+)
+
+// GeoIP 查询，国家库和 ASN 库分别为 MaxMind 的 Country/City 和 ASN 数据库，未配置的库不查询
+type geoLookup struct {
+    country *geoip2.Reader
+    asn     *geoip2.Reader
+}
+
+// 打开配置的 GeoIP 数据库，都未配置时返回nil
+func openGeoLookup(countryPath, asnPath string) (*geoLookup, error) {
+    if countryPath == "" && asnPath == "" {
+        return nil, nil
+    }
+    g := &geoLookup{}
+    var err error
+    if countryPath != "" {
+        if g.country, err = geoip2.Open(countryPath); err != nil {
+            return nil, fmt.Errorf("打开GeoIP数据库失败: %w", err)
+        }
+    }
+    if asnPath != "" {
+        if g.asn, err = geoip2.Open(asnPath); err != nil {
+            if g.country != nil {
+                g.country.Close()
+            }
+            return nil, fmt.Errorf("打开ASN数据库失败: %w", err)
+        }
+    }
+    return g, nil
+}
+
+// 查询IP所属国家代码和ASN，查不到的字段为空
+func (g *geoLookup) lookup(ip string) (country string, asn uint, org string) {
+    if g == nil {
+        return "", 0, ""
+    }
+    parsed := net.ParseIP(ip)
+    if parsed == nil {
+        return "", 0, ""
+    }
+    if g.country != nil {
+        if record, err := g.country.Country(parsed); err == nil {
+            country = record.Country.IsoCode
+        }
+    }
+    if g.asn != nil {
+        if record, err := g.asn.ASN(parsed); err == nil {
+            asn, org = record.AutonomousSystemNumber, record.AutonomousSystemOrganization
+        }
+    }
+    return country, asn, org
+}
@@ -0,0 +1,105 @@
+package scan
+
+import "fmt"
+
+// 结果文件表头语言
+const (
+    languageZH = "zh"
+    languageEN = "en"
+)
+
+// 校验表头语言配置
+func validateLanguage(lang string) error {
+    switch lang {
+    case languageZH, languageEN:
+        return nil
+    default:
+        return fmt.Errorf("未知的表头语言: %s（可选 zh、en）", lang)
+    }
+}
+
+// 各输出文件的列名，代码中统一使用中文列名，英文列名与 JSONL 输出的字段名一致，便于下游按固定的键解析
+var englishColumns = map[string]string{
+    "IP地址": "ip",
+    "端口": "port",
+    "模型名称": "model",
+    "状态": "status",
+    "模型大小": "size",
+    "参数量": "parameter_size",
+    "量化级别": "quantization_level",
+    "修改时间": "modified_at",
+    "已加载": "loaded",
+    "显存占用": "size_vram",
+    "向量维度": "embedding_dim",
+    "横幅": "banner",
+    "HTTP状态码": "http_status",
+    "协议": "scheme",
+    "国家": "country",
+    "ASN": "asn",
+    "ASN组织": "as_org",
+    "服务类型": "service_type",
+    "首Token延迟(ms)": "first_token_ms",
+    "Tokens/s": "tokens_per_sec",
+    "服务端Tokens/s": "eval_tokens_per_sec",
+    "墙钟Tokens/s": "wall_tokens_per_sec",
+    "生成Token数": "eval_count",
+    "提示词Token数": "prompt_eval_count",
+    "提示词长度": "prompt_length",
+    "并发流数": "streams",
+    "聚合Tokens/s": "aggregate_tps",
+    "单流衰减(%)": "stream_degradation_pct",
+    "较基线变化(%)": "baseline_delta_pct",
+    "探测点": "probe_label",
+    "加载耗时(ms)": "load_duration_ms",
+    "冷启动": "cold_start",
+    "采样次数": "samples",
+    "首Token延迟P95(ms)": "first_token_p95_ms",
+    "Tokens/s P95": "tokens_per_sec_p95",
+    "响应内容": "response",
+    "平均延迟(ms)": "latency_ms",
+    "失败原因": "reason",
+    "错误信息": "error",
+    "主机数": "hosts",
+    "示例地址": "examples",
+    "效率得分": "score",
+    "每美元Tokens": "tokens_per_dollar",
+    "阶段": "stage",
+    "数量": "count",
+}
+
+// 英文列名到中文列名
+var chineseColumns = func() map[string]string {
+    columns := make(map[string]string, len(englishColumns))
+    for zh, en := range englishColumns {
+        columns[en] = zh
+    }
+    return columns
+}()
+
+// 按语言转换表头，未收录的列名（如 captureHeaders 记录的响应头名称）保持不变
+func localizeHeader(lang string, header []string) []string {
+    if lang != languageEN {
+        return header
+    }
+    localized := make([]string, len(header))
+    for i, name := range header {
+        localized[i] = localizeColumn(lang, name)
+    }
+    return localized
+}
+
+// 按语言转换单个列名
+func localizeColumn(lang, name string) string {
+    if en, ok := englishColumns[name]; ok && lang == languageEN {
+        return en
+    }
+    return name
+}
+
+// 把任一语言的列名转换为中文列名，读取结果文件时按列名定位，不受表头语言影响
+func canonicalColumn(name string) string {
+    if zh, ok := chineseColumns[name]; ok {
+        return zh
+    }
+    return name
+}
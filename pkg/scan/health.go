@@ -0,0 +1,75 @@
+package scan
+
+import (
+    "encoding/json"
+    "fmt"
+    "log/slog"
+    "net"
+    "net/http"
+    "sync"
+    "time"
+)
+
+// 扫描器运行状态，供健康检查接口查询
+type healthState struct {
+    mu           sync.Mutex
+    stage        string
+    done         int
+    total        int
+    lastActivity time.Time
+}
+
+// 进入新阶段，total 为0表示总数未知，回到空闲时保留上一阶段的进度
+func (h *healthState) setStage(stage string, total int) {
+    h.mu.Lock()
+    defer h.mu.Unlock()
+    h.stage = stage
+    if stage != "idle" {
+        h.done = 0
+        h.total = total
+    }
+    h.lastActivity = time.Now()
+}
+
+// 直接计入已完成的目标，用于续测
+func (h *healthState) advance(n int) {
+    h.mu.Lock()
+    defer h.mu.Unlock()
+    h.done += n
+}
+
+// 完成一个目标
+func (h *healthState) touch() {
+    h.mu.Lock()
+    defer h.mu.Unlock()
+    h.done++
+    h.lastActivity = time.Now()
+}
+
+// 处理 /healthz 请求，进程存活即返回200，由调用方根据最后活动时间判断是否卡住
+func (h *healthState) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+    h.mu.Lock()
+    body := map[string]interface{}{
+        "stage":         h.stage,
+        "done":          h.done,
+        "total":         h.total,
+        "last_activity": h.lastActivity.Format(time.RFC3339),
+    }
+    h.mu.Unlock()
+
+    w.Header().Set("Content-Type", "application/json")
+    json.NewEncoder(w).Encode(body)
+}
+
+// 启动健康检查服务
+func (s *Scanner) startHealthServer(addr string) error {
+    listener, err := net.Listen("tcp", addr)
+    if err != nil {
+        return fmt.Errorf("健康检查服务监听失败: %w", err)
+    }
+    mux := http.NewServeMux()
+    mux.Handle("/healthz", s.health)
+    go http.Serve(listener, mux)
+    slog.Info("健康检查服务已启动", "url", fmt.Sprintf("http://%s/healthz", listener.Addr()))
+    return nil
+}
@@ -0,0 +1,123 @@
+package scan
+
+import (
+    "context"
+    "errors"
+    "fmt"
+    "log/slog"
+    "os"
+    "path/filepath"
+    "sync"
+)
+
+// 并行扫描任务，每个任务使用独立的扫描器和输出目录
+type ScanJob struct {
+    Name      string `mapstructure:"name"`
+    InputFile string `mapstructure:"inputFile"`
+    Rate      int    `mapstructure:"rate"`
+    Output    string `mapstructure:"output"`
+}
+
+// 以基础配置为模板生成任务配置，所有输出文件都放到任务的输出目录下
+func (j ScanJob) config(base *Config) *Config {
+    cfg := *base
+    cfg.Jobs = nil
+    cfg.InputFile = j.InputFile
+    if j.Rate > 0 {
+        cfg.Rate = j.Rate
+    }
+    // 健康检查和指标端口无法共享，任务不单独启动
+    cfg.HealthAddr = ""
+    cfg.MetricsAddr = ""
+
+    inDir := func(path string) string {
+        if path == "" {
+            return ""
+        }
+        return filepath.Join(j.Output, filepath.Base(path))
+    }
+    cfg.ScanOutputFile = inDir(cfg.ScanOutputFile)
+    cfg.OllamaOutputFile = inDir(cfg.OllamaOutputFile)
+    cfg.OutputFile = inDir(cfg.OutputFile)
+    cfg.CheckpointFile = inDir(cfg.CheckpointFile)
+    cfg.FailureSummaryFile = inDir(cfg.FailureSummaryFile)
+    cfg.CatalogFile = inDir(cfg.CatalogFile)
+    cfg.EfficiencyFile = inDir(cfg.EfficiencyFile)
+    cfg.ErrorFile = inDir(cfg.ErrorFile)
+    cfg.EmbedOutputFile = inDir(cfg.EmbedOutputFile)
+    // 各任务并发运行，数据库和检测断点不能共用
+    cfg.Database = inDir(cfg.Database)
+    cfg.DetectCheckpointFile = inDir(cfg.DetectCheckpointFile)
+    // 复制输出配置，文件类输出写到任务目录，不修改基础配置
+    cfg.Sinks = make([]SinkConfig, len(base.Sinks))
+    for i, sink := range base.Sinks {
+        if sink.Type == "jsonl" {
+            sink.Path = inDir(sink.Path)
+        }
+        cfg.Sinks[i] = sink
+    }
+    if cfg.ManifestDir != "" {
+        cfg.ManifestDir = j.Output
+    }
+    if cfg.SummaryDir != "" {
+        cfg.SummaryDir = j.Output
+    }
+    return &cfg
+}
+
+// 并行执行配置中的扫描任务，每个任务独立完成边扫描边检测
+func (s *Scanner) RunJobs(ctx context.Context) error {
+    if len(s.cfg.Jobs) == 0 {
+        return errors.New("未配置扫描任务")
+    }
+
+    var (
+        wg     sync.WaitGroup
+        mu     sync.Mutex
+        failed []string
+    )
+    for i, job := range s.cfg.Jobs {
+        if job.Name == "" {
+            job.Name = fmt.Sprintf("job%d", i+1)
+        }
+        if job.Output == "" {
+            job.Output = job.Name
+        }
+
+        wg.Add(1)
+        go func(job ScanJob) {
+            defer wg.Done()
+            err := s.runJob(ctx, job)
+            if err != nil {
+                slog.Error("任务失败", "job", job.Name, "error", err)
+                mu.Lock()
+                failed = append(failed, job.Name)
+                mu.Unlock()
+                return
+            }
+            slog.Info("任务完成", "job", job.Name, "output", job.Output)
+        }(job)
+    }
+    wg.Wait()
+
+    if len(failed) > 0 {
+        return fmt.Errorf("%d 个任务失败: %v", len(failed), failed)
+    }
+    return nil
+}
+
+// 使用独立的扫描器执行单个任务
+func (s *Scanner) runJob(ctx context.Context, job ScanJob) error {
+    if err := os.MkdirAll(job.Output, 0755); err != nil {
+        return fmt.Errorf("创建任务输出目录失败: %w", err)
+    }
+
+    scanner, err := NewScanner(job.config(s.cfg))
+    if err != nil {
+        return err
+    }
+    defer scanner.Close()
+
+    slog.Info("启动任务", "job", job.Name, "input", job.InputFile, "rate", scanner.cfg.Rate)
+    return scanner.ScanAndDetect(ctx)
+}
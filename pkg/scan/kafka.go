@@ -0,0 +1,71 @@
+package scan
+
+import (
+    "context"
+    "encoding/json"
+    "log/slog"
+    "sync/atomic"
+    "time"
+
+    "github.com/segmentio/kafka-go"
+)
+
+// Kafka 输出，按主机作为消息键异步批量发送结果
+type kafkaSink struct {
+    writer *kafka.Writer
+    failed int64
+}
+
+// 创建 Kafka 输出，未配置 broker 或 topic 时不启用
+func newKafkaSink(brokers []string, topic string) *kafkaSink {
+    if len(brokers) == 0 || topic == "" {
+        return nil
+    }
+    sink := &kafkaSink{}
+    sink.writer = &kafka.Writer{
+        Addr:         kafka.TCP(brokers...),
+        Topic:        topic,
+        Balancer:     &kafka.Hash{},
+        BatchTimeout: time.Second,
+        Async:        true,
+        // broker 不可用时只记录失败数，不影响扫描本身
+        Completion: func(messages []kafka.Message, err error) {
+            if err != nil {
+                atomic.AddInt64(&sink.failed, int64(len(messages)))
+            }
+        },
+    }
+    return sink
+}
+
+// 发送一条结果
+func (k *kafkaSink) publish(stage, host string, result interface{}) {
+    if k == nil {
+        return
+    }
+    value, err := json.Marshal(result)
+    if err != nil {
+        return
+    }
+    msg := kafka.Message{
+        Key:     []byte(host),
+        Value:   value,
+        Headers: []kafka.Header{{Key: "stage", Value: []byte(stage)}},
+    }
+    if err := k.writer.WriteMessages(context.Background(), msg); err != nil {
+        atomic.AddInt64(&k.failed, 1)
+    }
+}
+
+// 发送剩余消息并关闭连接
+func (k *kafkaSink) close() {
+    if k == nil {
+        return
+    }
+    if err := k.writer.Close(); err != nil {
+        slog.Warn("关闭Kafka连接失败", "error", err)
+    }
+    if failed := atomic.LoadInt64(&k.failed); failed > 0 {
+        slog.Warn("结果发送到Kafka失败", "count", failed)
+    }
+}
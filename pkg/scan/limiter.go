@@ -0,0 +1,124 @@
+package scan
+
+import (
+    "context"
+    "sync"
+
+    "golang.org/x/time/rate"
+)
+
+// 无法读取系统上限时使用的文件描述符数量
+const defaultFDLimit = 1024
+
+// 全局在途请求限制器，检测与性能测试共用，防止文件描述符耗尽
+type requestLimiter chan struct{}
+
+// 创建限制器，limit 为0时按文件描述符上限自动计算
+func newRequestLimiter(limit int) requestLimiter {
+    if limit <= 0 {
+        // 预留四分之一的描述符给日志、输出文件等其他用途
+        limit = fdLimit() * 3 / 4
+    }
+    if limit < 1 {
+        limit = 1
+    }
+    return make(requestLimiter, limit)
+}
+
+// 获取一个请求名额，ctx 取消时不再等待，返回其错误
+func (l requestLimiter) acquire(ctx context.Context) error {
+    select {
+    case l <- struct{}{}:
+        return nil
+    case <-ctx.Done():
+        return ctx.Err()
+    }
+}
+
+// 释放请求名额
+func (l requestLimiter) release() {
+    <-l
+}
+
+// 可调整上限的工作池，供自动扩缩容在运行中修改并发数
+type workerPool struct {
+    mu     sync.Mutex
+    cond   *sync.Cond
+    limit  int
+    active int
+    waited bool // 上次检查以来是否有任务等待过名额
+}
+
+// 创建工作池，done 关闭后等待中的 acquire 立即返回
+func newWorkerPool(limit int, done <-chan struct{}) *workerPool {
+    if limit < 1 {
+        limit = 1
+    }
+    p := &workerPool{limit: limit}
+    p.cond = sync.NewCond(&p.mu)
+    go func() {
+        <-done
+        p.mu.Lock()
+        p.cond.Broadcast()
+        p.mu.Unlock()
+    }()
+    return p
+}
+
+// 获取一个名额，done 关闭时返回false
+func (p *workerPool) acquire(done <-chan struct{}) bool {
+    p.mu.Lock()
+    defer p.mu.Unlock()
+    for p.active >= p.limit {
+        select {
+        case <-done:
+            return false
+        default:
+        }
+        p.waited = true
+        p.cond.Wait()
+    }
+    p.active++
+    return true
+}
+
+// 释放名额
+func (p *workerPool) release() {
+    p.mu.Lock()
+    defer p.mu.Unlock()
+    p.active--
+    p.cond.Signal()
+}
+
+// 修改并发上限，调小时已在运行的任务不受影响
+func (p *workerPool) setLimit(limit int) {
+    p.mu.Lock()
+    defer p.mu.Unlock()
+    p.limit = limit
+    p.cond.Broadcast()
+}
+
+// 返回当前上限，以及上次检查以来是否出现过名额不足
+func (p *workerPool) saturation() (int, bool) {
+    p.mu.Lock()
+    defer p.mu.Unlock()
+    waited := p.waited || p.active >= p.limit
+    p.waited = false
+    return p.limit, waited
+}
+
+// 创建检测限速器，perSec 为每秒探测的目标数，不大于0时不限速返回nil
+func newDetectLimiter(perSec float64) *rate.Limiter {
+    if perSec <= 0 {
+        return nil
+    }
+    return rate.NewLimiter(rate.Limit(perSec), 1)
+}
+
+// 等待检测限速器放行，所有检测协程共用同一个限速器，ctx 取消时返回错误
+func (s *Scanner) waitDetectRate(ctx context.Context) error {
+    if s.detectLimiter == nil {
+        return nil
+    }
+    return s.detectLimiter.Wait(ctx)
+}
@@ -0,0 +1,93 @@
+package scan
+
+import (
+    "fmt"
+    "io"
+    "log/slog"
+    "os"
+    "sync"
+)
+
+// 日志格式
+const (
+    logFormatText = "text"
+    logFormatJSON = "json"
+)
+
+// 解析日志级别: debug、info、warn、error
+func parseLogLevel(value string) (slog.Level, error) {
+    var level slog.Level
+    if err := level.UnmarshalText([]byte(value)); err != nil {
+        return level, fmt.Errorf("未知的日志级别: %s", value)
+    }
+    return level, nil
+}
+
+// 校验日志格式
+func validateLogFormat(format string) error {
+    switch format {
+    case logFormatText, logFormatJSON:
+        return nil
+    default:
+        return fmt.Errorf("未知的日志格式: %s", format)
+    }
+}
+
+// 按配置创建日志记录器并设为默认，未配置日志文件时写入标准错误，与标准输出的菜单和结果分开，
+// 返回值在进程退出前关闭；作为库使用时可以不调用，沿用调用方自己的默认日志记录器
+func SetupLogging(cfg *Config) (io.Closer, error) {
+    level, err := parseLogLevel(cfg.LogLevel)
+    if err != nil {
+        return nil, err
+    }
+
+    var out io.WriteCloser = nopCloser{stderrLog}
+    if cfg.LogFile != "" {
+        file, err := os.OpenFile(cfg.LogFile, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+        if err != nil {
+            return nil, fmt.Errorf("打开日志文件失败: %w", err)
+        }
+        out = file
+    }
+
+    opts := &slog.HandlerOptions{Level: level}
+    var handler slog.Handler
+    if cfg.LogFormat == logFormatJSON {
+        handler = slog.NewJSONHandler(out, opts)
+    } else {
+        handler = slog.NewTextHandler(out, opts)
+    }
+    slog.SetDefault(slog.New(handler))
+    return out, nil
+}
+
+// 写往标准错误的日志经由它输出，终端面板运行期间转入面板，避免日志覆盖画面
+var stderrLog = &redirectWriter{w: os.Stderr}
+
+// 可切换目标的输出
+type redirectWriter struct {
+    mu sync.Mutex
+    w  io.Writer
+}
+
+func (r *redirectWriter) Write(p []byte) (int, error) {
+    r.mu.Lock()
+    defer r.mu.Unlock()
+    return r.w.Write(p)
+}
+
+// 切换输出目标，返回原来的目标
+func (r *redirectWriter) redirect(w io.Writer) io.Writer {
+    r.mu.Lock()
+    defer r.mu.Unlock()
+    prev := r.w
+    r.w = w
+    return prev
+}
+
+// 标准错误不随日志关闭
+type nopCloser struct {
+    io.Writer
+}
+
+func (nopCloser) Close() error { return nil }
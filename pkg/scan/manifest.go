@@ -0,0 +1,102 @@
+package scan
+
+import (
+    "encoding/json"
+    "log/slog"
+    "os"
+    "path/filepath"
+    "sync"
+    "time"
+)
+
+// 阶段清单，记录每个阶段的输入输出、计数和执行结果，供外部编排系统读取
+type stageManifest struct {
+    Stage     string         `json:"stage"`
+    Inputs    []string       `json:"inputs"`
+    Outputs   []string       `json:"outputs"`
+    Counts    map[string]int `json:"counts"`
+    StartTime time.Time      `json:"start_time"`
+    EndTime   time.Time      `json:"end_time"`
+    Status    string         `json:"status"`
+    Error     string         `json:"error,omitempty"`
+    mu        sync.Mutex
+}
+
+// 开始记录阶段清单
+func newStageManifest(stage string, inputs, outputs []string) *stageManifest {
+    return &stageManifest{
+        Stage:     stage,
+        Inputs:    inputs,
+        Outputs:   outputs,
+        Counts:    make(map[string]int),
+        StartTime: time.Now(),
+    }
+}
+
+// 累加计数，可在工作协程中并发调用
+func (m *stageManifest) add(name string, delta int) {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+    m.Counts[name] += delta
+}
+
+// 阶段结束时写入清单文件，未配置 manifestDir 时跳过
+func (s *Scanner) writeManifest(m *stageManifest, stageErr error) {
+    if s.cfg.ManifestDir == "" {
+        return
+    }
+    m.mu.Lock()
+    defer m.mu.Unlock()
+
+    m.EndTime = time.Now()
+    m.Status = "success"
+    if stageErr != nil {
+        m.Status = "failed"
+        m.Error = stageErr.Error()
+    }
+
+    data, err := json.MarshalIndent(m, "", "  ")
+    if err != nil {
+        slog.Warn("生成阶段清单失败", "error", err)
+        return
+    }
+    path := filepath.Join(s.cfg.ManifestDir, m.Stage+".manifest.json")
+    if err := writeFileAtomic(path, data, s.fileMode); err != nil {
+        slog.Warn("写入阶段清单失败", "error", err)
+    }
+}
+
+// 先写临时文件再重命名，保证读取方不会看到写了一半的文件
+func writeFileAtomic(path string, data []byte, mode os.FileMode) error {
+    tmp := path + ".tmp"
+    file, err := createFile(tmp, mode)
+    if err != nil {
+        return err
+    }
+    if _, err := file.Write(data); err != nil {
+        file.Close()
+        return err
+    }
+    if err := file.Close(); err != nil {
+        return err
+    }
+    return os.Rename(tmp, path)
+}
+
+// 按指定权限打开输出文件，文件已存在时 OpenFile 不会修改权限，这里统一设置
+func openFile(path string, flag int, mode os.FileMode) (*os.File, error) {
+    file, err := os.OpenFile(path, flag, mode)
+    if err != nil {
+        return nil, err
+    }
+    if err := file.Chmod(mode); err != nil {
+        file.Close()
+        return nil, err
+    }
+    return file, nil
+}
+
+// 按指定权限创建或截断输出文件
+func createFile(path string, mode os.FileMode) (*os.File, error) {
+    return openFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, mode)
+}
@@ -0,0 +1,144 @@
+package scan
+
+import (
+    "encoding/csv"
+    "errors"
+    "fmt"
+    "io"
+    "log/slog"
+    "net"
+    "path/filepath"
+    "sort"
+    "strconv"
+    "strings"
+)
+
+// 单个探测点对某个(IP, 模型)组合的测量结果
+type probeMeasure struct {
+    latency string
+    tps     float64
+}
+
+// 合并后的一个(IP, 模型)组合
+type mergedRow struct {
+    ip, port, model string
+    probes          map[string]probeMeasure
+}
+
+// 合并多个探测点的性能测试结果写入 output，同一组合在各探测点的延迟和速度并列输出，lang 为表头语言
+func Merge(output, lang string, paths []string) error {
+    if err := validateLanguage(lang); err != nil {
+        return err
+    }
+
+    rows := make(map[string]*mergedRow)
+    var keys []string
+    labels := make(map[string]bool)
+    for _, path := range paths {
+        if err := mergeFile(path, rows, &keys, labels); err != nil {
+            return fmt.Errorf("读取 %s 失败: %w", path, err)
+        }
+    }
+
+    probeLabels := make([]string, 0, len(labels))
+    for label := range labels {
+        probeLabels = append(probeLabels, label)
+    }
+    sort.Strings(probeLabels)
+
+    file, err := createOutput(output, 0644)
+    if err != nil {
+        return fmt.Errorf("创建合并文件失败: %w", err)
+    }
+    defer file.Close()
+
+    writer := csv.NewWriter(file)
+    header := localizeHeader(lang, []string{"IP地址", "端口", "模型名称"})
+    for _, label := range probeLabels {
+        header = append(header,
+            label+" "+localizeColumn(lang, "首Token延迟(ms)"),
+            label+" "+localizeColumn(lang, "Tokens/s"))
+    }
+    writer.Write(header)
+    for _, key := range keys {
+        row := rows[key]
+        record := []string{row.ip, row.port, row.model}
+        for _, label := range probeLabels {
+            if m, ok := row.probes[label]; ok {
+                record = append(record, m.latency, fmt.Sprintf("%.2f", m.tps))
+            } else {
+                record = append(record, "", "")
+            }
+        }
+        writer.Write(record)
+    }
+    writer.Flush()
+    if err := writer.Error(); err != nil {
+        return fmt.Errorf("写入合并文件失败: %w", err)
+    }
+    slog.Info("合并完成",
+        "files", len(paths), "probes", len(probeLabels), "combinations", len(keys), "output", output)
+    return nil
+}
+
+// 读取一个结果文件，按表头定位各列（中英文表头均可），没有探测点列时使用文件名作为探测点
+func mergeFile(path string, rows map[string]*mergedRow, keys *[]string, labels map[string]bool) error {
+    file, err := openRead(path)
+    if err != nil {
+        return err
+    }
+    defer file.Close()
+
+    reader := csv.NewReader(file)
+    reader.FieldsPerRecord = -1
+    header, err := reader.Read()
+    if err != nil {
+        return err
+    }
+    columns := make(map[string]int, len(header))
+    for i, name := range header {
+        columns[canonicalColumn(name)] = i
+    }
+    for _, name := range []string{"IP地址", "端口", "模型名称", "状态", "首Token延迟(ms)", "Tokens/s"} {
+        if _, ok := columns[name]; !ok {
+            return fmt.Errorf("缺少列: %s", name)
+        }
+    }
+    labelColumn, hasLabel := columns["探测点"]
+    fileLabel := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+
+    for {
+        record, err := reader.Read()
+        if errors.Is(err, io.EOF) {
+            return nil
+        }
+        if err != nil {
+            return err
+        }
+        if len(record) < len(header) || record[columns["状态"]] != "成功" {
+            continue
+        }
+        tps, err := strconv.ParseFloat(record[columns["Tokens/s"]], 64)
+        if err != nil {
+            continue
+        }
+        label := fileLabel
+        if hasLabel && record[labelColumn] != "" {
+            label = record[labelColumn]
+        }
+        labels[label] = true
+
+        ip, model := record[columns["IP地址"]], record[columns["模型名称"]]
+        key := checkpointKey(net.JoinHostPort(ip, record[columns["端口"]]), model)
+        row, ok := rows[key]
+        if !ok {
+            row = &mergedRow{ip: ip, port: record[columns["端口"]], model: model, probes: make(map[string]probeMeasure)}
+            rows[key] = row
+            *keys = append(*keys, key)
+        }
+        // 同一探测点有多条记录时保留速度最高的一条
+        if prev, ok := row.probes[label]; !ok || tps > prev.tps {
+            row.probes[label] = probeMeasure{latency: record[columns["首Token延迟(ms)"]], tps: tps}
+        }
+    }
+}
@@ -0,0 +1,86 @@
+package scan
+
+import (
+    "fmt"
+    "log/slog"
+    "net"
+    "net/http"
+
+    "github.com/prometheus/client_golang/prometheus"
+    "github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Prometheus 指标，未配置 metricsAddr 时为nil，各方法直接返回
+type scanMetrics struct {
+    probed     prometheus.Counter
+    services   prometheus.Counter
+    benchmarks *prometheus.CounterVec
+    firstToken prometheus.Histogram
+}
+
+// 创建指标并在 addr 上提供 /metrics 接口
+func startMetricsServer(addr string) (*scanMetrics, error) {
+    if addr == "" {
+        return nil, nil
+    }
+    m := &scanMetrics{
+        probed: prometheus.NewCounter(prometheus.CounterOpts{
+            Name: "ollama_scan_targets_probed_total",
+            Help: "服务检测已探测的目标数",
+        }),
+        services: prometheus.NewCounter(prometheus.CounterOpts{
+            Name: "ollama_scan_services_found_total",
+            Help: "发现的 Ollama 服务数",
+        }),
+        benchmarks: prometheus.NewCounterVec(prometheus.CounterOpts{
+            Name: "ollama_scan_benchmarks_total",
+            Help: "性能测试次数，按结果区分",
+        }, []string{"result"}),
+        firstToken: prometheus.NewHistogram(prometheus.HistogramOpts{
+            Name:    "ollama_scan_first_token_seconds",
+            Help:    "成功测试的首Token延迟",
+            Buckets: prometheus.ExponentialBuckets(0.05, 2, 10),
+        }),
+    }
+    registry := prometheus.NewRegistry()
+    registry.MustRegister(m.probed, m.services, m.benchmarks, m.firstToken)
+
+    listener, err := net.Listen("tcp", addr)
+    if err != nil {
+        return nil, fmt.Errorf("指标服务监听失败: %w", err)
+    }
+    mux := http.NewServeMux()
+    mux.Handle("/metrics", promhttp.HandlerFor(registry, promhttp.HandlerOpts{}))
+    go http.Serve(listener, mux)
+    slog.Info("指标服务已启动", "url", fmt.Sprintf("http://%s/metrics", listener.Addr()))
+    return m, nil
+}
+
+// 完成一个目标的探测
+func (m *scanMetrics) probe() {
+    if m == nil {
+        return
+    }
+    m.probed.Inc()
+}
+
+// 发现一个服务
+func (m *scanMetrics) found() {
+    if m == nil {
+        return
+    }
+    m.services.Inc()
+}
+
+// 记录一次性能测试结果，成功时同时记录首Token延迟
+func (m *scanMetrics) bench(result BenchResult) {
+    if m == nil {
+        return
+    }
+    if result.Status != "成功" {
+        m.benchmarks.WithLabelValues("failure").Inc()
+        return
+    }
+    m.benchmarks.WithLabelValues("success").Inc()
+    m.firstToken.Observe(float64(result.FirstTokenMs) / 1000)
+}
@@ -0,0 +1,46 @@
+package scan
+
+import (
+    "regexp"
+    "strings"
+)
+
+// 把通配符规则转换为正则，* 匹配任意字符（包括 hf.co/用户/模型 中的 /），? 匹配单个字符
+func compileGlobs(patterns []string) []*regexp.Regexp {
+    compiled := make([]*regexp.Regexp, 0, len(patterns))
+    for _, pattern := range patterns {
+        expr := regexp.QuoteMeta(pattern)
+        expr = strings.ReplaceAll(expr, `\*`, ".*")
+        expr = strings.ReplaceAll(expr, `\?`, ".")
+        compiled = append(compiled, regexp.MustCompile("^"+expr+"$"))
+    }
+    return compiled
+}
+
+// 名称是否匹配任一规则
+func matchAny(patterns []*regexp.Regexp, name string) bool {
+    for _, re := range patterns {
+        if re.MatchString(name) {
+            return true
+        }
+    }
+    return false
+}
+
+// 按 includeModels/excludeModels 过滤模型，排除规则优先，未配置包含规则时保留全部
+func (s *Scanner) filterModels(models []ModelInfo) []ModelInfo {
+    if len(s.includeModels) == 0 && len(s.excludeModels) == 0 {
+        return models
+    }
+    var kept []ModelInfo
+    for _, m := range models {
+        if matchAny(s.excludeModels, m.Name) {
+            continue
+        }
+        if len(s.includeModels) > 0 && !matchAny(s.includeModels, m.Name) {
+            continue
+        }
+        kept = append(kept, m)
+    }
+    return kept
+}
@@ -0,0 +1,148 @@
+package scan
+
+import (
+    "bufio"
+    "context"
+    "fmt"
+    "log/slog"
+    "strings"
+    "sync"
+    "time"
+)
+
+// 纯Go的TCP连接扫描，不需要 zmap 和 root 权限
+// 逐个展开输入文件中的IP和CIDR，按 rate 限制每秒发起的连接数，由 maxWorkers 个协程并发连接
+// 每发现一个开放端口调用一次 found，格式与 zmap 输出一致，found 不会被并发调用
+func (s *Scanner) nativeScan(ctx context.Context, input string, found func(target string)) error {
+    file, err := openInput(input)
+    if err != nil {
+        return fmt.Errorf("读取输入文件失败: %w", err)
+    }
+    defer file.Close()
+
+    type probe struct {
+        ip   string
+        port int
+    }
+    probes := make(chan probe)
+    var foundMu sync.Mutex
+    var wg sync.WaitGroup
+    dialer := s.dialer(s.cfg.Timeout)
+    for i := 0; i < max(s.cfg.MaxWorkers, 1); i++ {
+        wg.Add(1)
+        go func() {
+            defer wg.Done()
+            for p := range probes {
+                conn, err := dialer.DialContext(ctx, hostPort(p.ip, p.port))
+                if err != nil {
+                    continue
+                }
+                conn.Close()
+                target := p.ip
+                if len(s.ports) > 1 {
+                    target = fmt.Sprintf("%s,%d", p.ip, p.port)
+                }
+                foundMu.Lock()
+                found(target)
+                foundMu.Unlock()
+            }
+        }()
+    }
+
+    // 按速率上限派发连接
+    rate, _ := s.scheduledRate(time.Now())
+    ticker := time.NewTicker(time.Second / time.Duration(max(rate, 1)))
+    defer ticker.Stop()
+    send := func(ip string) bool {
+        for _, port := range s.ports {
+            select {
+            case <-ticker.C:
+            case <-ctx.Done():
+                return false
+            }
+            select {
+            case probes <- probe{ip: ip, port: port}:
+            case <-ctx.Done():
+                return false
+            }
+        }
+        return true
+    }
+
+    reader := bufio.NewScanner(file)
+    var lineErr error
+    each := func(line string) bool {
+        line = strings.TrimSpace(line)
+        if line == "" || strings.HasPrefix(line, "#") {
+            return true
+        }
+        ok, err := eachIP(line, send)
+        if err != nil {
+            lineErr = err
+            return false
+        }
+        return ok
+    }
+    if s.cfg.Shuffle {
+        // 打乱需要先读入全部行，按行打乱，网段内部仍按顺序展开
+        var lines []string
+        for reader.Scan() {
+            lines = append(lines, reader.Text())
+        }
+        if reader.Err() == nil {
+            seed := shuffleTargets(lines, s.cfg.ShuffleSeed)
+            slog.Info("已打乱扫描目标顺序", "lines", len(lines), "seed", seed)
+            for _, line := range lines {
+                if !each(line) {
+                    break
+                }
+            }
+        }
+    } else {
+        for reader.Scan() {
+            if !each(reader.Text()) {
+                break
+            }
+        }
+    }
+    close(probes)
+    wg.Wait()
+
+    if lineErr != nil {
+        return lineErr
+    }
+    if err := reader.Err(); err != nil {
+        return fmt.Errorf("读取输入文件失败: %w", err)
+    }
+    return ctx.Err()
+}
+
+// 原生扫描并把开放的目标写入扫描结果文件
+func (s *Scanner) nativeScanFile(ctx context.Context, input string, manifest *stageManifest) error {
+    file, err := createOutput(s.cfg.ScanOutputFile, s.fileMode)
+    if err != nil {
+        return fmt.Errorf("创建扫描结果文件失败: %w", err)
+    }
+    writer := bufio.NewWriter(file)
+
+    slog.Info("执行原生TCP扫描", "ports", s.portSpec(), "workers", s.cfg.MaxWorkers)
+    scanErr := s.nativeScan(ctx, input, func(target string) {
+        fmt.Fprintln(writer, target)
+        manifest.add("hosts", 1)
+        s.health.touch()
+    })
+    if err := writer.Flush(); err != nil {
+        file.Close()
+        return fmt.Errorf("写入扫描结果失败: %w", err)
+    }
+    if err := file.Close(); err != nil {
+        return fmt.Errorf("写入扫描结果失败: %w", err)
+    }
+    if scanErr != nil {
+        if ctx.Err() != nil {
+            return errInterrupted
+        }
+        return fmt.Errorf("原生扫描失败: %w", scanErr)
+    }
+    return nil
+}
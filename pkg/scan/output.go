@@ -0,0 +1,265 @@
+package scan
+
+import (
+    "bufio"
+    "encoding/csv"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "io"
+    "log/slog"
+    "os"
+    "strconv"
+    "strings"
+)
+
+// 结果文件格式
+const (
+    outputCSV   = "csv"
+    outputJSONL = "jsonl"
+)
+
+// 校验结果文件格式配置
+func validateOutputFormat(format string) error {
+    switch format {
+    case outputCSV, outputJSONL:
+        return nil
+    default:
+        return fmt.Errorf("未知的输出格式: %s（可选 csv、jsonl）", format)
+    }
+}
+
+// 可写入结果文件的记录
+type resultRecord interface {
+    csvRecord(cfg *Config) []string
+}
+
+// 结果写入器，检测和性能测试通过它按配置的格式写入，调度逻辑与格式无关
+type resultWriter interface {
+    write(record resultRecord) error
+    flush() error
+}
+
+// 结果写入器的输出，写入可能经过压缩，Stat 用于判断是否为空文件
+type resultFile interface {
+    io.Writer
+    Stat() (os.FileInfo, error)
+}
+
+// 创建结果写入器，CSV 格式写入空文件时先写表头
+func (s *Scanner) newResultWriter(file resultFile, header []string) (resultWriter, error) {
+    if s.cfg.OutputFormat == outputJSONL {
+        return &jsonlWriter{w: bufio.NewWriter(file), out: file}, nil
+    }
+    w := &csvResultWriter{w: csv.NewWriter(file), out: file, cfg: s.cfg}
+    if info, err := file.Stat(); err == nil && info.Size() == 0 {
+        if err := w.w.Write(localizeHeader(s.cfg.Language, header)); err != nil {
+            return nil, err
+        }
+        w.w.Flush()
+    }
+    return w, nil
+}
+
+// CSV 格式写入器
+type csvResultWriter struct {
+    w   *csv.Writer
+    out io.Writer
+    cfg *Config
+}
+
+func (c *csvResultWriter) write(record resultRecord) error {
+    return c.w.Write(record.csvRecord(c.cfg))
+}
+
+func (c *csvResultWriter) flush() error {
+    c.w.Flush()
+    if err := c.w.Error(); err != nil {
+        return err
+    }
+    return flushOutput(c.out)
+}
+
+// JSONL 格式写入器，每行一个JSON对象
+type jsonlWriter struct {
+    w   *bufio.Writer
+    out io.Writer
+}
+
+func (j *jsonlWriter) write(record resultRecord) error {
+    data, err := json.Marshal(record)
+    if err != nil {
+        return err
+    }
+    j.w.Write(data)
+    return j.w.WriteByte('\n')
+}
+
+func (j *jsonlWriter) flush() error {
+    if err := j.w.Flush(); err != nil {
+        return err
+    }
+    return flushOutput(j.out)
+}
+
+// 读取检测结果中的性能测试目标，按配置的输出格式解析
+func (s *Scanner) readBenchTargets(path string) ([]benchTarget, error) {
+    file, err := openRead(path)
+    if err != nil {
+        return nil, err
+    }
+    defer file.Close()
+
+    var targets []benchTarget
+    if isJSONLFile(path, s.cfg.OutputFormat) {
+        err := readJSONLines(file, func(result DetectResult) {
+            if result.Status != "" && result.Status != "成功" {
+                return
+            }
+            result.IP = normalizeIP(result.IP)
+            s.rememberScheme(result.IP, result.Port, result.Scheme)
+            targets = append(targets, benchTarget{ip: result.IP, port: result.Port, model: result.Model, service: result.ServiceType})
+        })
+        return targets, err
+    }
+
+    // 单次解析，表头只读一次，带引号的字段（包括含换行的模型名称）由 csv 包处理
+    reader := csv.NewReader(file)
+    reader.FieldsPerRecord = -1
+    header, err := reader.Read()
+    if errors.Is(err, io.EOF) {
+        return nil, nil
+    }
+    if err != nil {
+        return nil, fmt.Errorf("读取表头失败: %w", err)
+    }
+    // 附加列随检测配置变化，按表头名称定位各列，中英文表头都能识别，缺少的基本列按固定位置读取
+    columns := map[string]int{"IP地址": 0, "端口": 1, "模型名称": 2, "状态": 3, "协议": -1, "服务类型": -1}
+    for i, name := range header {
+        if _, ok := columns[canonicalColumn(name)]; ok {
+            columns[canonicalColumn(name)] = i
+        }
+    }
+    field := func(record []string, name string) string {
+        if i := columns[name]; i >= 0 && i < len(record) {
+            return record[i]
+        }
+        return ""
+    }
+
+    for {
+        record, err := reader.Read()
+        if errors.Is(err, io.EOF) {
+            break
+        }
+        var parseErr *csv.ParseError
+        if errors.As(err, &parseErr) {
+            slog.Warn("无效记录", "line", parseErr.Line, "error", err)
+            continue
+        }
+        if err != nil {
+            return nil, err
+        }
+        if len(record) < 3 {
+            slog.Warn("无效记录", "record", strings.Join(record, ","))
+            continue
+        }
+        // 解析失败、受限、非Ollama等记录没有可测试的模型
+        if status := field(record, "状态"); status != "" && status != "成功" {
+            continue
+        }
+        ip := normalizeIP(field(record, "IP地址"))
+        port, err := strconv.Atoi(field(record, "端口"))
+        if err != nil {
+            port = s.defaultPort()
+        }
+        if scheme := field(record, "协议"); scheme != "" {
+            s.rememberScheme(ip, port, scheme)
+        }
+        targets = append(targets, benchTarget{ip: ip, port: port, model: field(record, "模型名称"), service: field(record, "服务类型")})
+    }
+    return targets, nil
+}
+
+// 读取手工整理的测试清单，每行 ip,port,model，端口为空时使用默认端口
+// 空行和 # 开头的行被忽略，首行是检测结果表头时跳过，因此也可以直接使用检测结果的前三列
+func (s *Scanner) readTargetList(path string) ([]benchTarget, error) {
+    file, err := openRead(path)
+    if err != nil {
+        return nil, err
+    }
+    defer file.Close()
+
+    reader := csv.NewReader(file)
+    reader.FieldsPerRecord = -1
+    reader.Comment = '#'
+    reader.TrimLeadingSpace = true
+    var targets []benchTarget
+    for first := true; ; first = false {
+        record, err := reader.Read()
+        if errors.Is(err, io.EOF) {
+            break
+        }
+        var parseErr *csv.ParseError
+        if errors.As(err, &parseErr) {
+            slog.Warn("无效记录", "line", parseErr.Line, "error", err)
+            continue
+        }
+        if err != nil {
+            return nil, err
+        }
+        if first && canonicalColumn(record[0]) == "IP地址" {
+            continue
+        }
+        if len(record) < 3 || record[0] == "" || record[2] == "" {
+            slog.Warn("无效记录", "record", strings.Join(record, ","))
+            continue
+        }
+        port := s.defaultPort()
+        if text := strings.TrimSpace(record[1]); text != "" {
+            if port, err = strconv.Atoi(text); err != nil || port < 1 || port > 65535 {
+                slog.Warn("无效端口", "record", strings.Join(record, ","))
+                continue
+            }
+        }
+        targets = append(targets, benchTarget{ip: normalizeIP(strings.TrimSpace(record[0])), port: port, model: record[2]})
+    }
+    return targets, nil
+}
+
+// 性能测试的输入文件，设置了 benchTargetsFile 时只测试其中列出的组合
+func (s *Scanner) benchInput() string {
+    if s.cfg.BenchTargetsFile != "" {
+        return s.cfg.BenchTargetsFile
+    }
+    return s.cfg.OllamaOutputFile
+}
+
+// 读取性能测试目标，设置了 limit 时只保留前N个
+func (s *Scanner) loadBenchTargets() ([]benchTarget, error) {
+    var targets []benchTarget
+    var err error
+    if s.cfg.BenchTargetsFile != "" {
+        if targets, err = s.readTargetList(s.cfg.BenchTargetsFile); err != nil {
+            return nil, fmt.Errorf("读取测试清单失败: %w", err)
+        }
+    } else if targets, err = s.readBenchTargets(s.cfg.OllamaOutputFile); err != nil {
+        return nil, fmt.Errorf("读取服务检测结果失败: %w", err)
+    }
+    // vLLM 等服务没有 Ollama 接口，只在使用 openai 接口时测试
+    kept := targets[:0]
+    for _, t := range targets {
+        if s.benchable(t.service) {
+            kept = append(kept, t)
+        }
+    }
+    if skipped := len(targets) - len(kept); skipped > 0 {
+        slog.Info("跳过不支持当前测试接口的服务", "count", skipped, "benchEndpoint", s.cfg.BenchEndpoint)
+    }
+    targets = kept
+    if s.cfg.Limit > 0 && len(targets) > s.cfg.Limit {
+        slog.Info("只测试前部分目标", "limit", s.cfg.Limit, "total", len(targets))
+        targets = targets[:s.cfg.Limit]
+    }
+    return targets, nil
+}
@@ -0,0 +1,99 @@
+package scan
+
+import (
+    "compress/gzip"
+    "errors"
+    "fmt"
+    "io/fs"
+    "log/slog"
+    "os"
+)
+
+// 结果文件写入期间的后缀，阶段完成后重命名为正式文件名，其他进程或下一阶段不会读到写了一半的结果
+const partialSuffix = ".partial"
+
+// 写入中的结果文件，逐条刷新到 .partial 文件，完成后原子地重命名为正式文件
+// 正式文件名以 .gz 结尾时压缩写入，续写时追加新的压缩段
+type partialFile struct {
+    *os.File
+    path     string // 正式文件路径
+    complete bool
+    gz       *gzip.Writer
+}
+
+// 打开结果文件的写入中版本，resume 为真时续写：已有 .partial 文件直接追加，
+// 否则先把上次的正式文件移过来再追加，保留已有结果
+func openPartial(path string, resume bool, mode os.FileMode) (*partialFile, error) {
+    partial := path + partialSuffix
+    if !resume {
+        file, err := createFile(partial, mode)
+        if err != nil {
+            return nil, err
+        }
+        return newPartialFile(file, path), nil
+    }
+    if _, err := os.Stat(partial); errors.Is(err, fs.ErrNotExist) {
+        if err := os.Rename(path, partial); err != nil && !errors.Is(err, fs.ErrNotExist) {
+            return nil, err
+        }
+    }
+    file, err := openFile(partial, os.O_CREATE|os.O_WRONLY|os.O_APPEND, mode)
+    if err != nil {
+        return nil, err
+    }
+    return newPartialFile(file, path), nil
+}
+
+func newPartialFile(file *os.File, path string) *partialFile {
+    f := &partialFile{File: file, path: path}
+    if isGzip(path) {
+        f.gz = gzip.NewWriter(file)
+    }
+    return f
+}
+
+// 写入结果，压缩文件经 gzip 写入
+func (f *partialFile) Write(p []byte) (int, error) {
+    if f.gz != nil {
+        return f.gz.Write(p)
+    }
+    return f.File.Write(p)
+}
+
+// 把压缩缓冲中的数据写入文件，保证断点中记录的目标都已落盘
+func (f *partialFile) Flush() error {
+    if f.gz != nil {
+        return f.gz.Flush()
+    }
+    return nil
+}
+
+// 关闭文件，complete 为真时重命名为正式文件，否则保留 .partial 文件供续测
+func (f *partialFile) close(complete bool) error {
+    if f.gz != nil {
+        if err := f.gz.Close(); err != nil {
+            f.File.Close()
+            return err
+        }
+    }
+    if err := f.File.Close(); err != nil {
+        return err
+    }
+    if !complete {
+        slog.Info("结果未完成，保留在临时文件中", "file", f.Name())
+        return nil
+    }
+    if err := os.Rename(f.Name(), f.path); err != nil {
+        return fmt.Errorf("重命名结果文件失败: %w", err)
+    }
+    f.complete = true
+    return nil
+}
+
+// 关闭后结果所在的路径，完成时为正式文件，否则为 .partial 文件
+func (f *partialFile) location() string {
+    if f.complete {
+        return f.path
+    }
+    return f.Name()
+}
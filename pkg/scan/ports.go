@@ -0,0 +1,203 @@
+package scan
+
+import (
+    "bufio"
+    "context"
+    "fmt"
+    "io"
+    "net"
+    "strconv"
+    "strings"
+)
+
+// 解析端口列表，支持逗号分隔的单个端口和范围，如 "11434,8080,5000-5010"
+func parsePorts(spec string) ([]int, error) {
+    var ports []int
+    seen := make(map[int]bool)
+    for _, part := range strings.Split(spec, ",") {
+        part = strings.TrimSpace(part)
+        if part == "" {
+            continue
+        }
+        low, high, isRange := strings.Cut(part, "-")
+        start, err := strconv.Atoi(strings.TrimSpace(low))
+        if err != nil {
+            return nil, fmt.Errorf("无效的端口: %s", part)
+        }
+        end := start
+        if isRange {
+            if end, err = strconv.Atoi(strings.TrimSpace(high)); err != nil {
+                return nil, fmt.Errorf("无效的端口范围: %s", part)
+            }
+        }
+        if start < 1 || end > 65535 || start > end {
+            return nil, fmt.Errorf("无效的端口范围: %s", part)
+        }
+        for port := start; port <= end; port++ {
+            if !seen[port] {
+                seen[port] = true
+                ports = append(ports, port)
+            }
+        }
+    }
+    if len(ports) == 0 {
+        return nil, fmt.Errorf("端口列表为空")
+    }
+    return ports, nil
+}
+
+// 本次扫描的端口列表，未配置 ports 时只使用 port
+func (c *Config) portList() ([]int, error) {
+    if strings.TrimSpace(c.Ports) == "" {
+        return []int{c.Port}, nil
+    }
+    return parsePorts(c.Ports)
+}
+
+// 拆分目标行，多端口扫描时 zmap 输出 "IP,端口"，单端口时只有IP，使用默认端口
+func splitTarget(line string, defaultPort int) (string, int) {
+    ip, portText, ok := strings.Cut(strings.TrimSpace(line), ",")
+    ip = normalizeIP(ip)
+    if !ok {
+        return ip, defaultPort
+    }
+    port, err := strconv.Atoi(strings.TrimSpace(portText))
+    if err != nil {
+        return ip, defaultPort
+    }
+    return ip, port
+}
+
+// 去掉IPv6地址外的方括号，如 "[2001:db8::1]"，结果文件和请求地址统一使用裸地址
+func normalizeIP(ip string) string {
+    ip = strings.TrimSpace(ip)
+    return strings.TrimSuffix(strings.TrimPrefix(ip, "["), "]")
+}
+
+// 去掉重复和空白的目标行，保持首次出现的顺序，masscan 等扫描程序可能多次输出同一个IP
+func dedupeTargets(lines []string) []string {
+    seen := make(map[string]bool, len(lines))
+    targets := lines[:0]
+    for _, line := range lines {
+        line = strings.TrimSpace(line)
+        if line == "" || seen[line] {
+            continue
+        }
+        seen[line] = true
+        targets = append(targets, line)
+    }
+    return targets
+}
+
+// 统计目标行展开后的探测目标数
+func (s *Scanner) countTargets(lines []string) int {
+    total := 0
+    for _, line := range lines {
+        line = strings.TrimSpace(line)
+        if line == "" || strings.Contains(line, ",") {
+            total = saturatingAdd(total, 1)
+            continue
+        }
+        total = saturatingAdd(total, saturatingMul(countIPs(line), len(s.ports)))
+    }
+    return total
+}
+
+// 逐行统计输入展开后的探测目标数，与 streamReader 一样跳过重复的行
+func (s *Scanner) countReader(r io.Reader) (int, error) {
+    total := 0
+    seen := make(map[string]bool)
+    scanner := bufio.NewScanner(r)
+    for scanner.Scan() {
+        line := strings.TrimSpace(scanner.Text())
+        if line == "" || seen[line] {
+            continue
+        }
+        seen[line] = true
+        total = saturatingAdd(total, s.countTargets([]string{line}))
+    }
+    return total, scanner.Err()
+}
+
+// 逐个发送探测目标，CIDR网段流式展开为单个IP，只有IP的目标展开到每个配置的端口，
+// 已带端口的目标和无法解析的行保持不变，ctx 取消时停止
+func (s *Scanner) streamTargets(ctx context.Context, lines []string, targets chan<- string) {
+    send := sender(ctx, targets)
+    for _, line := range lines {
+        if !s.expandTarget(strings.TrimSpace(line), send) {
+            return
+        }
+    }
+}
+
+// 逐行读取目标并按 streamTargets 的规则展开发送，读到一行发送一行，不把整个输入读入内存，
+// 重复的行只发送一次，ctx 取消时停止
+func (s *Scanner) streamReader(ctx context.Context, r io.Reader, targets chan<- string) error {
+    send := sender(ctx, targets)
+    seen := make(map[string]bool)
+    scanner := bufio.NewScanner(r)
+    for scanner.Scan() {
+        line := strings.TrimSpace(scanner.Text())
+        if line == "" || seen[line] {
+            continue
+        }
+        seen[line] = true
+        if !s.expandTarget(line, send) {
+            return nil
+        }
+    }
+    return scanner.Err()
+}
+
+// 返回向 targets 发送目标的函数，ctx 取消时返回 false
+func sender(ctx context.Context, targets chan<- string) func(string) bool {
+    return func(target string) bool {
+        select {
+        case targets <- target:
+            return true
+        case <-ctx.Done():
+            return false
+        }
+    }
+}
+
+// 展开一行目标并逐个发送，发送被取消时返回 false
+func (s *Scanner) expandTarget(line string, send func(string) bool) bool {
+    if line == "" || strings.Contains(line, ",") {
+        return send(line)
+    }
+    ok, err := eachIP(line, func(ip string) bool {
+        if len(s.ports) <= 1 {
+            return send(ip)
+        }
+        for _, port := range s.ports {
+            if !send(fmt.Sprintf("%s,%d", ip, port)) {
+                return false
+            }
+        }
+        return true
+    })
+    if err != nil {
+        // 交给探测阶段按连接失败处理
+        ok = send(line)
+    }
+    return ok
+}
+
+// 拼接主机地址，IPv6地址加方括号（如 [2001:db8::1]:11434），用于请求地址和结果去重
+func hostPort(ip string, port int) string {
+    return net.JoinHostPort(ip, strconv.Itoa(port))
+}
+
+// 传给扫描程序的端口参数
+func (s *Scanner) portSpec() string {
+    if len(s.ports) > 1 {
+        return strings.ReplaceAll(s.cfg.Ports, " ", "")
+    }
+    return strconv.Itoa(s.defaultPort())
+}
+
+// 目标未带端口时使用的端口，ports 只有一个端口时以它为准，与扫描实际使用的端口一致
+func (s *Scanner) defaultPort() int {
+    return s.ports[0]
+}
@@ -0,0 +1,96 @@
+package scan
+
+import (
+    "fmt"
+    "io"
+    "os"
+    "os/exec"
+)
+
+// 预处理命令的输出，关闭时等待命令退出
+type preprocessReader struct {
+    io.ReadCloser
+    cmd   *exec.Cmd
+    input io.Closer
+}
+
+// 关闭输出并等待命令退出，先关闭管道避免命令阻塞在写入上
+func (p *preprocessReader) Close() error {
+    p.ReadCloser.Close()
+    p.input.Close()
+    if err := p.cmd.Wait(); err != nil {
+        return fmt.Errorf("预处理命令执行失败: %w", err)
+    }
+    return nil
+}
+
+// 输入文件名为 - 时从标准输入读取，便于管道使用
+const stdinPath = "-"
+
+// 打开输入文件，文件名为 - 时返回标准输入，.gz 结尾时透明解压
+func openInput(path string) (io.ReadCloser, error) {
+    if path == stdinPath {
+        return os.Stdin, nil
+    }
+    return openRead(path)
+}
+
+// 打开目标列表，配置了 inputPreprocessor 时返回经命令处理后的输出
+func (s *Scanner) openTargets(path string) (io.ReadCloser, error) {
+    file, err := openInput(path)
+    if err != nil {
+        return nil, err
+    }
+    if s.cfg.InputPreprocessor == "" {
+        return file, nil
+    }
+
+    cmd := exec.Command("sh", "-c", s.cfg.InputPreprocessor)
+    cmd.Stdin = file
+    cmd.Stderr = s.dash.output(os.Stderr)
+    stdout, err := cmd.StdoutPipe()
+    if err != nil {
+        file.Close()
+        return nil, err
+    }
+    if err := cmd.Start(); err != nil {
+        file.Close()
+        return nil, fmt.Errorf("预处理命令启动失败: %w", err)
+    }
+    return &preprocessReader{ReadCloser: stdout, cmd: cmd, input: file}, nil
+}
+
+// 准备 zmap 的输入文件，需要预处理或解压时写入临时文件，返回文件路径和清理函数
+func (s *Scanner) scanInput() (string, func(), error) {
+    // 原生扫描自行解压，zmap 和 masscan 只能读取未压缩的文件
+    if s.cfg.InputPreprocessor == "" && (s.cfg.Scanner == scannerNative || !isGzip(s.cfg.InputFile)) {
+        if s.cfg.InputFile == stdinPath && s.cfg.Scanner != scannerNative {
+            // zmap 和 masscan 只接受文件路径
+            return "/dev/stdin", func() {}, nil
+        }
+        return s.cfg.InputFile, func() {}, nil
+    }
+
+    targets, err := s.openTargets(s.cfg.InputFile)
+    if err != nil {
+        return "", nil, err
+    }
+    tmp, err := os.CreateTemp("", "scan-input-*.txt")
+    if err != nil {
+        targets.Close()
+        return "", nil, err
+    }
+    cleanup := func() { os.Remove(tmp.Name()) }
+
+    _, copyErr := io.Copy(tmp, targets)
+    closeErr := targets.Close()
+    tmp.Close()
+    if copyErr != nil || closeErr != nil {
+        cleanup()
+        if copyErr != nil {
+            return "", nil, copyErr
+        }
+        return "", nil, closeErr
+    }
+    return tmp.Name(), cleanup, nil
+}
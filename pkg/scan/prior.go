@@ -0,0 +1,219 @@
+package scan
+
+import (
+    "bufio"
+    "bytes"
+    "encoding/csv"
+    "encoding/json"
+    "errors"
+    "io"
+    "log/slog"
+    "sort"
+    "strconv"
+    "strings"
+)
+
+// 判断历史结果文件是否为 JSONL 格式，按扩展名（可带 .gz）判断，扩展名无法区分时使用配置的输出格式
+func isJSONLFile(path, format string) bool {
+    name := strings.TrimSuffix(path, gzipSuffix)
+    switch {
+    case strings.HasSuffix(name, ".jsonl"):
+        return true
+    case strings.HasSuffix(name, ".csv"):
+        return false
+    }
+    return format == outputJSONL
+}
+
+// 逐行解码 JSONL 文件，跳过空行，无法解析的行记录警告后跳过
+func readJSONLines[T any](r io.Reader, fn func(T)) error {
+    scanner := bufio.NewScanner(r)
+    scanner.Buffer(nil, 1024*1024)
+    for scanner.Scan() {
+        line := scanner.Bytes()
+        if len(bytes.TrimSpace(line)) == 0 {
+            continue
+        }
+        var record T
+        if err := json.Unmarshal(line, &record); err != nil {
+            slog.Warn("无效记录", "record", string(line), "error", err)
+            continue
+        }
+        fn(record)
+    }
+    return scanner.Err()
+}
+
+// 读取历史性能测试结果，CSV 按表头定位各列，中英文表头都能识别
+func readPriorBench(path, format string) ([]BenchResult, error) {
+    file, err := openRead(path)
+    if err != nil {
+        return nil, err
+    }
+    defer file.Close()
+
+    var results []BenchResult
+    if isJSONLFile(path, format) {
+        err := readJSONLines(file, func(result BenchResult) {
+            results = append(results, result)
+        })
+        return results, err
+    }
+
+    reader := csv.NewReader(file)
+    reader.FieldsPerRecord = -1
+    header, err := reader.Read()
+    if errors.Is(err, io.EOF) {
+        return nil, nil
+    }
+    if err != nil {
+        return nil, err
+    }
+    // 失败原因列只在开启 skipPreviousFailures 时写入，缺少的基本列按固定位置读取
+    columns := map[string]int{"IP地址": 0, "端口": 1, "模型名称": 2, "状态": 3, "首Token延迟(ms)": 4, "Tokens/s": 5, "失败原因": -1}
+    for i, name := range header {
+        if _, ok := columns[canonicalColumn(name)]; ok {
+            columns[canonicalColumn(name)] = i
+        }
+    }
+    field := func(record []string, name string) string {
+        if i := columns[name]; i >= 0 && i < len(record) {
+            return record[i]
+        }
+        return ""
+    }
+    for {
+        record, err := reader.Read()
+        if errors.Is(err, io.EOF) {
+            break
+        }
+        if err != nil {
+            return nil, err
+        }
+        port, err := strconv.Atoi(field(record, "端口"))
+        if err != nil {
+            continue
+        }
+        tps, _ := strconv.ParseFloat(field(record, "Tokens/s"), 64)
+        firstToken, _ := strconv.ParseInt(field(record, "首Token延迟(ms)"), 10, 64)
+        results = append(results, BenchResult{
+            IP:           field(record, "IP地址"),
+            Port:         port,
+            Model:        field(record, "模型名称"),
+            Status:       field(record, "状态"),
+            FirstTokenMs: firstToken,
+            TokensPerSec: tps,
+            Reason:       field(record, "失败原因"),
+        })
+    }
+    return results, nil
+}
+
+// 读取历史性能测试结果，返回每个(IP, 模型)组合测得的最高生成速度
+func loadPriorResults(path, format string) (map[string]float64, error) {
+    results, err := readPriorBench(path, format)
+    if err != nil {
+        return nil, err
+    }
+    prior := make(map[string]float64)
+    for _, result := range results {
+        if result.Status != "成功" {
+            continue
+        }
+        key := checkpointKey(hostPort(result.IP, result.Port), result.Model)
+        if result.TokensPerSec > prior[key] {
+            prior[key] = result.TokensPerSec
+        }
+    }
+    return prior, nil
+}
+
+// 确定性失败，重试也不会成功：端口拒绝连接、模型不存在、令牌被拒绝
+// 超时、连接被重置等可能是暂时的，不计入
+func definiteFailure(status, reason string) bool {
+    switch status {
+    case "令牌被拒绝", "HTTP 404":
+        return true
+    case "连接失败":
+        return reason == "连接被拒绝"
+    }
+    return false
+}
+
+// 读取历史性能测试结果，返回最近一次确定性失败的(IP, 模型)组合
+// CSV 的失败原因列只在开启 skipPreviousFailures 时写入，旧结果文件中的连接失败无法区分原因，不会被跳过
+func loadPriorFailures(path, format string) (map[string]bool, error) {
+    results, err := readPriorBench(path, format)
+    if err != nil {
+        return nil, err
+    }
+    failures := make(map[string]bool)
+    for _, result := range results {
+        // 同一组合出现多次时以最后一条为准
+        key := checkpointKey(hostPort(result.IP, result.Port), result.Model)
+        failures[key] = definiteFailure(result.Status, result.Reason)
+    }
+    for key, failed := range failures {
+        if !failed {
+            delete(failures, key)
+        }
+    }
+    return failures, nil
+}
+
+// 读取历史检测结果，返回曾经成功响应的IP集合
+func loadPriorHosts(path, format string) (map[string]bool, error) {
+    file, err := openRead(path)
+    if err != nil {
+        return nil, err
+    }
+    defer file.Close()
+
+    hosts := make(map[string]bool)
+    if isJSONLFile(path, format) {
+        err := readJSONLines(file, func(result DetectResult) {
+            if result.Status == "成功" {
+                hosts[result.IP] = true
+            }
+        })
+        return hosts, err
+    }
+
+    reader := csv.NewReader(file)
+    reader.FieldsPerRecord = -1
+    for {
+        record, err := reader.Read()
+        if errors.Is(err, io.EOF) {
+            break
+        }
+        if err != nil {
+            return nil, err
+        }
+        if len(record) < 4 || record[3] != "成功" {
+            continue
+        }
+        hosts[record[0]] = true
+    }
+    return hosts, nil
+}
+
+// 目标行中的IP部分
+func targetIP(line string) string {
+    ip, _ := splitTarget(line, 0)
+    return ip
+}
+
+// 把历史上响应过的IP排到前面，其余保持原有顺序，返回排到前面的数量
+func prioritizeHosts(ips []string, known map[string]bool) int {
+    sort.SliceStable(ips, func(i, j int) bool {
+        return known[targetIP(ips[i])] && !known[targetIP(ips[j])]
+    })
+    count := 0
+    for _, ip := range ips {
+        if !known[targetIP(ip)] {
+            break
+        }
+        count++
+    }
+    return count
+}
@@ -0,0 +1,77 @@
+package scan
+
+import (
+    "fmt"
+    "os"
+    "strconv"
+    "sync"
+)
+
+// 服务检测失败记录，区分主机不可达、端口被过滤和非200响应
+type ProbeError struct {
+    IP     string `json:"ip"`
+    Port   int    `json:"port"`
+    Reason string `json:"reason"`
+    Error  string `json:"error"`
+}
+
+// 失败记录表头，与 csvRecord 的列一一对应
+var probeErrorHeader = []string{"IP地址", "端口", "失败原因", "错误信息"}
+
+func (e ProbeError) csvRecord(cfg *Config) []string {
+    return []string{e.IP, strconv.Itoa(e.Port), e.Reason, e.Error}
+}
+
+// 检测失败记录文件，未配置 errorFile 时为nil
+type probeErrorLog struct {
+    mu     sync.Mutex
+    file   *os.File
+    writer resultWriter
+}
+
+// 打开失败记录文件，从断点继续时追加写入
+func (s *Scanner) openProbeErrorLog(resumed bool) (*probeErrorLog, error) {
+    if s.cfg.ErrorFile == "" {
+        return nil, nil
+    }
+    var file *os.File
+    var err error
+    if resumed {
+        file, err = openFile(s.cfg.ErrorFile, os.O_CREATE|os.O_WRONLY|os.O_APPEND, s.fileMode)
+    } else {
+        file, err = createFile(s.cfg.ErrorFile, s.fileMode)
+    }
+    if err != nil {
+        return nil, fmt.Errorf("创建失败记录文件失败: %w", err)
+    }
+    writer, err := s.newResultWriter(file, probeErrorHeader)
+    if err != nil {
+        file.Close()
+        return nil, fmt.Errorf("写入失败记录表头失败: %w", err)
+    }
+    return &probeErrorLog{file: file, writer: writer}, nil
+}
+
+// 记录一次探测失败
+func (l *probeErrorLog) write(ip string, port int, err error) {
+    if l == nil {
+        return
+    }
+    l.mu.Lock()
+    defer l.mu.Unlock()
+    l.writer.write(ProbeError{IP: ip, Port: port, Reason: classifyError(err), Error: err.Error()})
+}
+
+// 写入缓冲的记录并关闭文件
+func (l *probeErrorLog) close() error {
+    if l == nil {
+        return nil
+    }
+    l.mu.Lock()
+    defer l.mu.Unlock()
+    if err := l.writer.flush(); err != nil {
+        l.file.Close()
+        return err
+    }
+    return l.file.Close()
+}
@@ -0,0 +1,78 @@
+package scan
+
+import (
+    "fmt"
+    "io"
+    "sync/atomic"
+
+    "github.com/cheggaaa/pb/v3"
+)
+
+// 阶段进度，计数使用原子操作，可在多个协程中并发调用，nil 时各方法不做任何事
+// 总数已知时计数不会超过总数，即使重复计数也不会显示超过100%；总数未知（流式输入）时显示转动指示和速率
+type progress struct {
+    bar   *pb.ProgressBar
+    total int64
+    done  atomic.Int64
+}
+
+// 创建进度，total 为0表示总数未知，面板模式下进度由面板展示，进度条不输出
+func (s *Scanner) newProgress(label string, total int) *progress {
+    template := fmt.Sprintf(`{{ %q }} {{counters . }} {{ bar . "[" "=" ">" "." "]" }} {{percent . }}`, label+":")
+    if total <= 0 {
+        total = 0
+        template = fmt.Sprintf(`{{ %q }} {{ cycle . "|" "/" "-" "\\" }} {{counters . }} {{speed . "%%s/s" "?/s" }}`, label+":")
+    }
+    bar := pb.New(total)
+    bar.SetTemplateString(template)
+    if s.dash != nil {
+        bar.SetWriter(io.Discard)
+    }
+    return &progress{bar: bar, total: int64(total)}
+}
+
+// 开始刷新显示
+func (p *progress) start() {
+    if p == nil {
+        return
+    }
+    p.bar.Start()
+}
+
+// 完成一个目标，达到总数后不再增加
+func (p *progress) increment() {
+    if p == nil {
+        return
+    }
+    for {
+        done := p.done.Load()
+        if p.total > 0 && done >= p.total {
+            return
+        }
+        if p.done.CompareAndSwap(done, done+1) {
+            p.bar.Increment()
+            return
+        }
+    }
+}
+
+// 设置已完成数量，用于续测时从断点进度开始，只能在开始派发任务前调用
+func (p *progress) setCurrent(n int) {
+    if p == nil {
+        return
+    }
+    done := int64(n)
+    if p.total > 0 && done > p.total {
+        done = p.total
+    }
+    p.done.Store(done)
+    p.bar.SetCurrent(done)
+}
+
+// 停止刷新并输出最终状态，可重复调用
+func (p *progress) finish() {
+    if p == nil {
+        return
+    }
+    p.bar.Finish()
+}
@@ -0,0 +1,55 @@
+package scan
+
+import "regexp"
+
+// 提示词长度档位
+type PromptLength struct {
+    Name     string `mapstructure:"name"`
+    Template string `mapstructure:"template"`
+    Length   int    `mapstructure:"length"`
+}
+
+// 重复模板直到达到目标字符数，未设置长度时直接使用模板
+func (p PromptLength) prompt() string {
+    template := []rune(p.Template)
+    if p.Length <= 0 || len(template) == 0 {
+        return p.Template
+    }
+    prompt := make([]rune, 0, p.Length+len(template))
+    for len(prompt) < p.Length {
+        prompt = append(prompt, template...)
+    }
+    return string(prompt[:p.Length])
+}
+
+// 按模型名称选用的提示词，model 为通配符规则，规则同 includeModels
+// 用列表而不是以规则为键的映射，配置读取时键中的 . 会被当作层级分隔符（如 qwen2.5*），且列表的匹配顺序确定
+type ModelPrompt struct {
+    Model  string `mapstructure:"model"`
+    Prompt string `mapstructure:"prompt"`
+}
+
+// 编译后的模型提示词规则
+type modelPrompt struct {
+    pattern *regexp.Regexp
+    prompt  string
+}
+
+// 编译模型提示词规则，保持配置顺序
+func compileModelPrompts(prompts []ModelPrompt) []modelPrompt {
+    compiled := make([]modelPrompt, len(prompts))
+    for i, p := range prompts {
+        compiled[i] = modelPrompt{pattern: compileGlobs([]string{p.Model})[0], prompt: p.Prompt}
+    }
+    return compiled
+}
+
+// 模型使用的提示词，按配置顺序取第一条匹配的规则，都不匹配时使用 benchPrompt
+func (s *Scanner) promptFor(modelName string) string {
+    for _, p := range s.benchPrompts {
+        if p.pattern.MatchString(modelName) {
+            return p.prompt
+        }
+    }
+    return s.cfg.BenchPrompt
+}
@@ -0,0 +1,137 @@
+package scan
+
+import (
+    "bufio"
+    "context"
+    "crypto/tls"
+    "fmt"
+    "net"
+    "net/http"
+    "net/url"
+    "time"
+
+    "golang.org/x/net/proxy"
+)
+
+// 支持的代理协议
+const (
+    proxyHTTP   = "http"
+    proxyHTTPS  = "https"
+    proxySOCKS5 = "socks5"
+)
+
+// 解析代理地址，为空时返回nil
+func parseProxy(raw string) (*url.URL, error) {
+    if raw == "" {
+        return nil, nil
+    }
+    u, err := url.Parse(raw)
+    if err != nil {
+        return nil, fmt.Errorf("代理地址解析失败: %w", err)
+    }
+    switch u.Scheme {
+    case proxyHTTP, proxyHTTPS, proxySOCKS5:
+    default:
+        return nil, fmt.Errorf("不支持的代理协议: %q（可选 http、https、socks5）", u.Scheme)
+    }
+    if u.Hostname() == "" || u.Port() == "" {
+        return nil, fmt.Errorf("代理地址缺少主机或端口: %s", raw)
+    }
+    return u, nil
+}
+
+// 目标连接拨号器，设置代理时经代理建立连接，原生扫描和横幅抓取共用
+type targetDialer struct {
+    timeout time.Duration
+    proxy   *url.URL
+    tls     *tls.Config // 连接 https 代理使用
+}
+
+// 连接目标，超时同时覆盖与代理的握手
+func (d targetDialer) DialContext(ctx context.Context, addr string) (net.Conn, error) {
+    if d.timeout > 0 {
+        var cancel context.CancelFunc
+        ctx, cancel = context.WithTimeout(ctx, d.timeout)
+        defer cancel()
+    }
+    direct := &net.Dialer{}
+    if d.proxy == nil {
+        return direct.DialContext(ctx, "tcp", addr)
+    }
+    if d.proxy.Scheme == proxySOCKS5 {
+        dialer, err := proxy.FromURL(d.proxy, direct)
+        if err != nil {
+            return nil, err
+        }
+        return dialer.(proxy.ContextDialer).DialContext(ctx, "tcp", addr)
+    }
+    return d.dialConnect(ctx, direct, addr)
+}
+
+// 通过 HTTP CONNECT 建立隧道，代理返回200表示已连上目标
+func (d targetDialer) dialConnect(ctx context.Context, direct *net.Dialer, addr string) (net.Conn, error) {
+    conn, err := direct.DialContext(ctx, "tcp", d.proxy.Host)
+    if err != nil {
+        return nil, fmt.Errorf("连接代理失败: %w", err)
+    }
+    if d.proxy.Scheme == proxyHTTPS {
+        config := d.tls.Clone()
+        config.ServerName = d.proxy.Hostname()
+        conn = tls.Client(conn, config)
+    }
+    // 握手阶段遵守 ctx 的截止时间，建立后由调用方自行设置
+    if deadline, ok := ctx.Deadline(); ok {
+        conn.SetDeadline(deadline)
+    }
+    stop := context.AfterFunc(ctx, func() { conn.SetDeadline(time.Unix(1, 0)) })
+    defer stop()
+
+    req := &http.Request{
+        Method: http.MethodConnect,
+        URL:    &url.URL{Opaque: addr},
+        Host:   addr,
+        Header: make(http.Header),
+    }
+    if user := d.proxy.User; user != nil {
+        password, _ := user.Password()
+        req.SetBasicAuth(user.Username(), password)
+        req.Header.Set("Proxy-Authorization", req.Header.Get("Authorization"))
+        req.Header.Del("Authorization")
+    }
+    if err := req.Write(conn); err != nil {
+        conn.Close()
+        return nil, fmt.Errorf("发送代理请求失败: %w", err)
+    }
+    reader := bufio.NewReader(conn)
+    resp, err := http.ReadResponse(reader, req)
+    if err != nil {
+        conn.Close()
+        return nil, fmt.Errorf("读取代理响应失败: %w", err)
+    }
+    resp.Body.Close()
+    if resp.StatusCode != http.StatusOK {
+        conn.Close()
+        return nil, fmt.Errorf("代理拒绝连接 %s: %s", addr, resp.Status)
+    }
+    conn.SetDeadline(time.Time{})
+    // 目标在隧道建立后立即发送的数据（如横幅）可能已被读入缓冲
+    if reader.Buffered() > 0 {
+        return &bufferedConn{Conn: conn, reader: reader}, nil
+    }
+    return conn, nil
+}
+
+// 先读取缓冲中剩余数据的连接
+type bufferedConn struct {
+    net.Conn
+    reader *bufio.Reader
+}
+
+func (c *bufferedConn) Read(p []byte) (int, error) {
+    return c.reader.Read(p)
+}
+
+// 创建使用配置代理的拨号器
+func (s *Scanner) dialer(timeout time.Duration) targetDialer {
+    return targetDialer{timeout: timeout, proxy: s.proxy, tls: s.tlsConfig}
+}
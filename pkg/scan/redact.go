@@ -0,0 +1,128 @@
+package scan
+
+import (
+    "crypto/sha256"
+    "encoding/hex"
+    "fmt"
+    "net"
+    "path/filepath"
+    "strings"
+)
+
+// IP脱敏方式
+const (
+    redactNone = "none"
+    redactMask = "mask"
+    redactHash = "hash"
+)
+
+// 校验脱敏配置，hash 方式未设置盐值时可以通过枚举地址空间反查，直接拒绝
+func validateRedact(mode, salt string) error {
+    switch mode {
+    case redactNone, redactMask:
+        return nil
+    case redactHash:
+        if salt == "" {
+            return fmt.Errorf("redactIP 为 hash 时需要设置 redactSalt，否则可以通过枚举地址反查")
+        }
+        return nil
+    default:
+        return fmt.Errorf("未知的IP脱敏方式: %s（可选 none、mask、hash）", mode)
+    }
+}
+
+// 写出结果前对IP脱敏，内部处理始终使用真实IP
+func (s *Scanner) redactIP(ip string) string {
+    switch s.cfg.RedactIP {
+    case redactMask:
+        return maskLastOctet(ip)
+    case redactHash:
+        // 盐值由 validateRedact 保证非空，防止通过枚举地址空间反查
+        sum := sha256.Sum256([]byte(s.cfg.RedactSalt + ip))
+        return hex.EncodeToString(sum[:8])
+    default:
+        return ip
+    }
+}
+
+// 隐藏IPv4最后一段，IPv6只保留/64前缀
+func maskLastOctet(ip string) string {
+    parsed := net.ParseIP(ip)
+    if parsed == nil {
+        return ip
+    }
+    if v4 := parsed.To4(); v4 != nil {
+        octets := strings.Split(v4.String(), ".")
+        return strings.Join(octets[:3], ".") + ".x"
+    }
+    return parsed.Mask(net.CIDRMask(64, 128)).String() + "x"
+}
+
+// 可脱敏的结果记录，返回IP已替换的副本
+type redactableRecord interface {
+    resultRecord
+    redacted(redact func(string) string) resultRecord
+}
+
+func (r DetectResult) redacted(redact func(string) string) resultRecord {
+    r.IP = redact(r.IP)
+    return r
+}
+
+func (r BenchResult) redacted(redact func(string) string) resultRecord {
+    r.IP = redact(r.IP)
+    return r
+}
+
+func (r EmbedResult) redacted(redact func(string) string) resultRecord {
+    r.IP = redact(r.IP)
+    return r
+}
+
+// 脱敏副本的路径，在扩展名前插入 .redacted，如 ollama.csv -> ollama.redacted.csv
+func redactedPath(path string) string {
+    base := strings.TrimSuffix(path, gzipSuffix)
+    ext := filepath.Ext(base)
+    return strings.TrimSuffix(base, ext) + ".redacted" + ext + strings.TrimPrefix(path, base)
+}
+
+// 结果文件保留真实IP，供后续阶段、基线对比和跳过历史失败读取；开启脱敏时另写一份脱敏副本用于分享
+type redactedCopy struct {
+    main   resultWriter
+    shared resultWriter
+    redact func(string) string
+}
+
+func (c *redactedCopy) write(record resultRecord) error {
+    if err := c.main.write(record); err != nil {
+        return err
+    }
+    if r, ok := record.(redactableRecord); ok {
+        record = r.redacted(c.redact)
+    }
+    return c.shared.write(record)
+}
+
+func (c *redactedCopy) flush() error {
+    if err := c.main.flush(); err != nil {
+        return err
+    }
+    return c.shared.flush()
+}
+
+// 未开启脱敏时原样返回写入器；否则打开脱敏副本，返回同时写入两份文件的写入器和副本文件
+func (s *Scanner) withRedactedCopy(main resultWriter, path string, resume bool, header []string) (resultWriter, *partialFile, error) {
+    if s.cfg.RedactIP == redactNone {
+        return main, nil, nil
+    }
+    file, err := openPartial(redactedPath(path), resume, s.fileMode)
+    if err != nil {
+        return nil, nil, fmt.Errorf("创建脱敏结果文件失败: %w", err)
+    }
+    shared, err := s.newResultWriter(file, header)
+    if err != nil {
+        file.close(false)
+        return nil, nil, fmt.Errorf("写入脱敏结果表头失败: %w", err)
+    }
+    return &redactedCopy{main: main, shared: shared, redact: s.redactIP}, file, nil
+}
@@ -0,0 +1,73 @@
+package scan
+
+import (
+    "context"
+    "log/slog"
+    "sort"
+)
+
+// 对同一模型重复测试 BenchRepeat 次，首次作为预热丢弃，其余样本取中位数和P95
+// 预热失败说明服务不可用，直接返回失败结果；之后的失败样本不参与统计，全部失败时返回最后一次的结果
+func (s *Scanner) benchmarkRepeated(ctx context.Context, ip string, port int, modelName, prompt string) BenchResult {
+    warm := s.benchmarkModel(ctx, ip, port, modelName, prompt)
+    if warm.Status != "成功" {
+        return warm
+    }
+
+    var samples []BenchResult
+    last := warm
+    for i := 1; i < s.cfg.BenchRepeat && ctx.Err() == nil; i++ {
+        last = s.benchmarkModel(ctx, ip, port, modelName, prompt)
+        if last.Status == "成功" {
+            samples = append(samples, last)
+        }
+    }
+    if len(samples) == 0 {
+        return last
+    }
+
+    firstToken := make([]float64, len(samples))
+    tps := make([]float64, len(samples))
+    evalTps := make([]float64, len(samples))
+    wallTps := make([]float64, len(samples))
+    for i, sample := range samples {
+        firstToken[i] = float64(sample.FirstTokenMs)
+        tps[i] = sample.TokensPerSec
+        evalTps[i] = sample.EvalTokensPerSec
+        wallTps[i] = sample.WallTokensPerSec
+    }
+
+    // 以最后一个成功样本为基础，保留其Token数和响应内容，冷启动信息沿用预热请求
+    result := samples[len(samples)-1]
+    result.LoadDurationMs = warm.LoadDurationMs
+    result.ColdStart = warm.ColdStart
+    result.Samples = len(samples)
+    result.FirstTokenMs = int64(percentile(firstToken, 0.5))
+    result.FirstTokenP95Ms = int64(percentile(firstToken, 0.95))
+    result.TokensPerSec = percentile(tps, 0.5)
+    // 速度越低越差，P95取慢的一端，即95%的样本不低于该值
+    result.TokensPerSecP95 = percentile(tps, 0.05)
+    result.EvalTokensPerSec = percentile(evalTps, 0.5)
+    result.WallTokensPerSec = percentile(wallTps, 0.5)
+    slog.Debug("重复测试",
+        "ip", ip,
+        "model", modelName,
+        "samples", len(samples),
+        "first_token_p95_ms", result.FirstTokenP95Ms,
+        "tps_p95", result.TokensPerSecP95)
+    return result
+}
+
+// 计算分位数，p 取0到1，样本之间线性插值，values 会被排序
+func percentile(values []float64, p float64) float64 {
+    if len(values) == 0 {
+        return 0
+    }
+    sort.Float64s(values)
+    pos := p * float64(len(values)-1)
+    lower := int(pos)
+    if lower+1 >= len(values) {
+        return values[len(values)-1]
+    }
+    return values[lower] + (values[lower+1]-values[lower])*(pos-float64(lower))
+}
@@ -0,0 +1,244 @@
+package scan
+
+import (
+    "fmt"
+    "strconv"
+    "time"
+)
+
+// 服务检测结果
+type DetectResult struct {
+    IP                string            `json:"ip"`
+    Port              int               `json:"port"`
+    Model             string            `json:"model"`
+    Status            string            `json:"status"`
+    Size              int64             `json:"size,omitempty"`
+    ParameterSize     string            `json:"parameter_size,omitempty"`
+    QuantizationLevel string            `json:"quantization_level,omitempty"`
+    ModifiedAt        time.Time         `json:"modified_at"`
+    Loaded            bool              `json:"loaded,omitempty"`
+    SizeVRAM          int64             `json:"size_vram,omitempty"`
+    EmbeddingDim      int               `json:"embedding_dim,omitempty"`
+    Headers           map[string]string `json:"headers,omitempty"`
+    Banner            string            `json:"banner,omitempty"`
+    HTTPStatus        int               `json:"http_status,omitempty"`
+    Scheme            string            `json:"scheme,omitempty"`
+    Country           string            `json:"country,omitempty"`
+    ASN               uint              `json:"asn,omitempty"`
+    ASOrg             string            `json:"as_org,omitempty"`
+    ServiceType       string            `json:"service_type,omitempty"`
+}
+
+// 检测结果表头，与 csvRecord 的列一一对应
+func detectHeader(cfg *Config) []string {
+    header := []string{"IP地址", "端口", "模型名称", "状态", "模型大小", "参数量", "量化级别", "修改时间"}
+    if cfg.ProbeRunning {
+        header = append(header, "已加载", "显存占用")
+    }
+    if cfg.ProbeEmbeddings {
+        header = append(header, "向量维度")
+    }
+    header = append(header, cfg.CaptureHeaders...)
+    if cfg.BannerGrab {
+        header = append(header, "横幅")
+    }
+    if len(cfg.RestrictedStatuses) > 0 {
+        header = append(header, "HTTP状态码")
+    }
+    if cfg.Scheme != schemeHTTP {
+        header = append(header, "协议")
+    }
+    if cfg.GeoIPDatabase != "" {
+        header = append(header, "国家")
+    }
+    if cfg.GeoIPASNDatabase != "" {
+        header = append(header, "ASN", "ASN组织")
+    }
+    if cfg.ProbeOtherServers {
+        header = append(header, "服务类型")
+    }
+    return header
+}
+
+// 转换为CSV记录，模型信息列固定输出，按配置追加加载状态、向量维度、响应头、横幅、状态码、协议、GeoIP和服务类型列
+func (r DetectResult) csvRecord(cfg *Config) []string {
+    record := []string{
+        r.IP,
+        strconv.Itoa(r.Port),
+        r.Model,
+        r.Status,
+        "",
+        r.ParameterSize,
+        r.QuantizationLevel,
+        "",
+    }
+    // 未获取到模型信息的记录（受限、非Ollama等）留空
+    if r.Size > 0 {
+        record[4] = strconv.FormatInt(r.Size, 10)
+    }
+    if !r.ModifiedAt.IsZero() {
+        record[7] = r.ModifiedAt.Format(time.RFC3339)
+    }
+    if cfg.ProbeRunning {
+        loaded, vram := "否", ""
+        if r.Loaded {
+            loaded, vram = "是", strconv.FormatInt(r.SizeVRAM, 10)
+        }
+        if r.Status != "成功" {
+            loaded = ""
+        }
+        record = append(record, loaded, vram)
+    }
+    if cfg.ProbeEmbeddings {
+        record = append(record, strconv.Itoa(r.EmbeddingDim))
+    }
+    for _, name := range cfg.CaptureHeaders {
+        record = append(record, r.Headers[name])
+    }
+    if cfg.BannerGrab {
+        record = append(record, r.Banner)
+    }
+    if len(cfg.RestrictedStatuses) > 0 {
+        code := ""
+        if r.HTTPStatus > 0 {
+            code = strconv.Itoa(r.HTTPStatus)
+        }
+        record = append(record, code)
+    }
+    if cfg.Scheme != schemeHTTP {
+        record = append(record, r.Scheme)
+    }
+    if cfg.GeoIPDatabase != "" {
+        record = append(record, r.Country)
+    }
+    if cfg.GeoIPASNDatabase != "" {
+        asn := ""
+        if r.ASN > 0 {
+            asn = strconv.FormatUint(uint64(r.ASN), 10)
+        }
+        record = append(record, asn, r.ASOrg)
+    }
+    if cfg.ProbeOtherServers {
+        record = append(record, r.ServiceType)
+    }
+    return record
+}
+
+// 性能测试结果
+type BenchResult struct {
+    IP                string   `json:"ip"`
+    Port              int      `json:"port"`
+    Model             string   `json:"model"`
+    Status            string   `json:"status"`
+    FirstTokenMs      int64    `json:"first_token_ms"`
+    TokensPerSec      float64  `json:"tokens_per_sec"`
+    EvalTokensPerSec  float64  `json:"eval_tokens_per_sec,omitempty"`
+    WallTokensPerSec  float64  `json:"wall_tokens_per_sec,omitempty"`
+    EvalCount         int      `json:"eval_count,omitempty"`
+    PromptEvalCount   int      `json:"prompt_eval_count,omitempty"`
+    PromptLength      string   `json:"prompt_length,omitempty"`
+    Streams           int      `json:"streams,omitempty"`
+    AggregateTps      float64  `json:"aggregate_tps,omitempty"`
+    StreamDegradation float64  `json:"stream_degradation,omitempty"`
+    BaselineDelta     *float64 `json:"baseline_delta,omitempty"`
+    ProbeLabel        string   `json:"probe_label,omitempty"`
+    LoadDurationMs    int64    `json:"load_duration_ms,omitempty"`
+    ColdStart         *bool    `json:"cold_start,omitempty"`
+    Samples           int      `json:"samples,omitempty"`
+    FirstTokenP95Ms   int64    `json:"first_token_p95_ms,omitempty"`
+    TokensPerSecP95   float64  `json:"tokens_per_sec_p95,omitempty"`
+    Response          string   `json:"response,omitempty"`
+    // 失败原因分类，用于统计和判断下次运行是否跳过
+    Reason            string   `json:"reason,omitempty"`
+}
+
+// 性能测试结果表头，与 csvRecord 的列一一对应
+func benchHeader(cfg *Config) []string {
+    header := []string{"IP地址", "端口", "模型名称", "状态", "首Token延迟(ms)", "Tokens/s",
+        "服务端Tokens/s", "墙钟Tokens/s", "生成Token数", "提示词Token数"}
+    if len(cfg.PromptLengths) > 0 {
+        header = append(header, "提示词长度")
+    }
+    if cfg.multiStream() {
+        header = append(header, "并发流数", "聚合Tokens/s", "单流衰减(%)")
+    }
+    if cfg.BaselineFile != "" {
+        header = append(header, "较基线变化(%)")
+    }
+    if cfg.ProbeLabel != "" {
+        header = append(header, "探测点")
+    }
+    if cfg.ColdStartThreshold > 0 {
+        header = append(header, "加载耗时(ms)", "冷启动")
+    }
+    if cfg.BenchRepeat > 1 {
+        header = append(header, "采样次数", "首Token延迟P95(ms)", "Tokens/s P95")
+    }
+    if cfg.SkipPreviousFailures {
+        header = append(header, "失败原因")
+    }
+    if cfg.SaveResponse {
+        header = append(header, "响应内容")
+    }
+    return header
+}
+
+// 转换为CSV记录，按配置追加提示词档位、多路测试、基线对比和响应内容列
+func (r BenchResult) csvRecord(cfg *Config) []string {
+    record := []string{
+        r.IP,
+        strconv.Itoa(r.Port),
+        r.Model,
+        r.Status,
+        strconv.FormatInt(r.FirstTokenMs, 10),
+        fmt.Sprintf("%.2f", r.TokensPerSec),
+        "",
+        fmt.Sprintf("%.2f", r.WallTokensPerSec),
+        strconv.Itoa(r.EvalCount),
+        strconv.Itoa(r.PromptEvalCount),
+    }
+    // 缺少结束帧时服务端速度留空
+    if r.EvalTokensPerSec > 0 {
+        record[6] = fmt.Sprintf("%.2f", r.EvalTokensPerSec)
+    }
+    if len(cfg.PromptLengths) > 0 {
+        record = append(record, r.PromptLength)
+    }
+    if cfg.multiStream() {
+        record = append(record,
+            strconv.Itoa(r.Streams),
+            fmt.Sprintf("%.2f", r.AggregateTps),
+            fmt.Sprintf("%.1f", r.StreamDegradation*100))
+    }
+    if cfg.BaselineFile != "" {
+        delta := ""
+        if r.BaselineDelta != nil {
+            delta = fmt.Sprintf("%+.1f", *r.BaselineDelta*100)
+        }
+        record = append(record, delta)
+    }
+    if cfg.ProbeLabel != "" {
+        record = append(record, r.ProbeLabel)
+    }
+    if cfg.ColdStartThreshold > 0 {
+        cold := ""
+        if r.ColdStart != nil {
+            cold = strconv.FormatBool(*r.ColdStart)
+        }
+        record = append(record, strconv.FormatInt(r.LoadDurationMs, 10), cold)
+    }
+    if cfg.BenchRepeat > 1 {
+        record = append(record,
+            strconv.Itoa(r.Samples),
+            strconv.FormatInt(r.FirstTokenP95Ms, 10),
+            fmt.Sprintf("%.2f", r.TokensPerSecP95))
+    }
+    // 记录失败原因，下次运行据此区分连接被拒绝和暂时性的超时
+    if cfg.SkipPreviousFailures {
+        record = append(record, r.Reason)
+    }
+    if cfg.SaveResponse {
+        record = append(record, r.Response)
+    }
+    return record
+}
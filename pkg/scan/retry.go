@@ -0,0 +1,53 @@
+package scan
+
+import (
+    "context"
+    "errors"
+    "io"
+    "math/rand"
+    "net"
+    "syscall"
+    "time"
+)
+
+// 计算重试等待时间，开启 retryJitter 时在 [0, base) 内随机取值，
+// 避免大量协程同时重试形成同步的请求峰值
+func (s *Scanner) retryDelay(base time.Duration) time.Duration {
+    if !s.cfg.RetryJitter || base <= 0 {
+        return base
+    }
+    return time.Duration(rand.Int63n(int64(base)))
+}
+
+// 判断错误是否值得重试：超时、连接被重置等临时网络错误和5xx响应
+// 连接被拒绝说明端口未开放，4xx 说明服务明确拒绝，解析失败另有 decodeRetries 控制，均不重试
+func transientError(err error) bool {
+    var se *statusError
+    var netErr net.Error
+    switch {
+    case err == nil, errors.Is(err, errDecode), errors.Is(err, context.Canceled):
+        return false
+    case errors.As(err, &se):
+        return se.code >= 500
+    case errors.Is(err, syscall.ECONNREFUSED):
+        return false
+    case errors.Is(err, syscall.ECONNRESET), errors.Is(err, io.ErrUnexpectedEOF), errors.Is(err, io.EOF):
+        return true
+    case errors.As(err, &netErr):
+        return true
+    default:
+        return false
+    }
+}
+
+// 等待重试，ctx 取消时提前返回false
+func sleepContext(ctx context.Context, d time.Duration) bool {
+    timer := time.NewTimer(d)
+    defer timer.Stop()
+    select {
+    case <-timer.C:
+        return true
+    case <-ctx.Done():
+        return false
+    }
+}
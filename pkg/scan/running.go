@@ -0,0 +1,47 @@
+package scan
+
+import (
+    "context"
+    "encoding/json"
+    "fmt"
+    "net/http"
+    "time"
+)
+
+// /api/ps 返回的已加载模型
+type RunningModel struct {
+    Name      string    `json:"name"`
+    Size      int64     `json:"size"`
+    SizeVRAM  int64     `json:"size_vram"`
+    ExpiresAt time.Time `json:"expires_at"`
+}
+
+// 请求 /api/ps 获取当前加载在内存中的模型及显存占用
+func (s *Scanner) getRunningModels(ctx context.Context, ip string, port int) ([]RunningModel, error) {
+    if err := s.inflight.acquire(ctx); err != nil {
+        return nil, err
+    }
+    defer s.inflight.release()
+
+    req, err := http.NewRequestWithContext(ctx, "GET", s.serviceURL(ip, port, "/api/ps"), nil)
+    if err != nil {
+        return nil, err
+    }
+    s.authorize(req, ip, port)
+    resp, err := s.httpClient.Do(req)
+    if err != nil {
+        return nil, err
+    }
+    defer resp.Body.Close()
+    if resp.StatusCode != http.StatusOK {
+        return nil, &statusError{code: resp.StatusCode}
+    }
+
+    var data struct {
+        Models []RunningModel `json:"models"`
+    }
+    if err := json.NewDecoder(resp.Body).Decode(&data); err != nil {
+        return nil, fmt.Errorf("%w: %v", errDecode, err)
+    }
+    return data.Models, nil
+}
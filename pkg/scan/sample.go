@@ -0,0 +1,137 @@
+package scan
+
+import (
+    "bufio"
+    "fmt"
+    "io"
+    "math/rand"
+    "strings"
+    "time"
+)
+
+// 目标抽样策略
+const (
+    sampleUniform   = "uniform"
+    samplePerSubnet = "per-subnet"
+)
+
+// 蓄水池，流式读取时保持固定大小的均匀样本
+type reservoir struct {
+    items []string
+    seen  int
+}
+
+// 向蓄水池加入一个元素
+func (r *reservoir) add(item string, size int, rng *rand.Rand) {
+    r.seen++
+    if len(r.items) < size {
+        r.items = append(r.items, item)
+        return
+    }
+    if j := rng.Intn(r.seen); j < size {
+        r.items[j] = item
+    }
+}
+
+// 校验抽样策略配置
+func validateSampleStrategy(strategy string) error {
+    switch strategy {
+    case "", sampleUniform, samplePerSubnet:
+        return nil
+    default:
+        return fmt.Errorf("未知的抽样策略: %s（可选 uniform、per-subnet）", strategy)
+    }
+}
+
+// 从目标列表中流式抽样
+func sampleTargets(r io.Reader, strategy string, size int) ([]string, error) {
+    rng := rand.New(rand.NewSource(time.Now().UnixNano()))
+    switch strategy {
+    case "", sampleUniform:
+        return sampleUniformly(r, size, rng)
+    case samplePerSubnet:
+        return sampleBySubnet(r, size, rng)
+    default:
+        return nil, fmt.Errorf("未知的抽样策略: %s", strategy)
+    }
+}
+
+// 对全部目标做均匀抽样
+func sampleUniformly(r io.Reader, size int, rng *rand.Rand) ([]string, error) {
+    pool := &reservoir{}
+    scanner := bufio.NewScanner(r)
+    for scanner.Scan() {
+        line := strings.TrimSpace(scanner.Text())
+        if line == "" {
+            continue
+        }
+        pool.add(line, size, rng)
+    }
+    return pool.items, scanner.Err()
+}
+
+// 按/16子网分层抽样，各子网轮流出样，避免单个子网占据整个样本
+func sampleBySubnet(r io.Reader, size int, rng *rand.Rand) ([]string, error) {
+    pools := make(map[string]*reservoir)
+    var subnets []string
+
+    scanner := bufio.NewScanner(r)
+    for scanner.Scan() {
+        line := strings.TrimSpace(scanner.Text())
+        if line == "" {
+            continue
+        }
+        key := maskIP(line, 16, 48)
+        pool, ok := pools[key]
+        if !ok {
+            pool = &reservoir{}
+            pools[key] = pool
+            subnets = append(subnets, key)
+        }
+        pool.add(line, size, rng)
+    }
+    if err := scanner.Err(); err != nil {
+        return nil, err
+    }
+
+    // 打乱子网顺序，样本数无法整除时不偏向文件靠前的子网
+    rng.Shuffle(len(subnets), func(i, j int) {
+        subnets[i], subnets[j] = subnets[j], subnets[i]
+    })
+
+    var samples []string
+    for len(samples) < size {
+        picked := false
+        for _, key := range subnets {
+            pool := pools[key]
+            if len(pool.items) == 0 {
+                continue
+            }
+            i := rng.Intn(len(pool.items))
+            samples = append(samples, pool.items[i])
+            pool.items[i] = pool.items[len(pool.items)-1]
+            pool.items = pool.items[:len(pool.items)-1]
+            picked = true
+            if len(samples) == size {
+                break
+            }
+        }
+        if !picked {
+            break
+        }
+    }
+    return samples, nil
+}
+
+// 用给定种子打乱目标顺序，扫描和检测的压力分散到不同网段；种子为0时按当前时间生成，
+// 返回实际使用的种子，写入日志后可用 shuffleSeed 复现同样的顺序
+func shuffleTargets(targets []string, seed int64) int64 {
+    if seed == 0 {
+        seed = time.Now().UnixNano()
+    }
+    rng := rand.New(rand.NewSource(seed))
+    rng.Shuffle(len(targets), func(i, j int) {
+        targets[i], targets[j] = targets[j], targets[i]
+    })
+    return seed
+}
@@ -0,0 +1,955 @@
+// Package scan 实现 Ollama 服务的端口扫描、服务检测和性能测试。
+// 配置可以用 LoadConfig 从文件读取，也可以从 DefaultConfig 开始在代码中修改，再交给 NewScanner 创建扫描器，
+// 命令行程序见仓库根目录的 main.go
+package scan
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"os/signal"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/time/rate"
+)
+
+// 配置结构体
+type Config struct {
+    // zmap 扫描相关配置    
+    Port           int           `mapstructure:"port"`
+    InputFile      string        `mapstructure:"inputFile"` 
+    OutputFile     string        `mapstructure:"outputFile"`
+    Rate           int           `mapstructure:"rate"`
+    Bandwidth      string        `mapstructure:"bandwidth"`
+    // ollama 检测服务相关配置
+    MaxWorkers     int           `mapstructure:"maxWorkers"`
+    MaxIdleConns   int           `mapstructure:"maxIdleConns"`
+    Timeout        time.Duration `mapstructure:"timeout"`
+    IdleConnTimeout time.Duration `mapstructure:"idleConnTimeout"`
+    ProbeEmbeddings bool         `mapstructure:"probeEmbeddings"`
+    // ollama 性能测试相关配置
+    BenchPrompt    string        `mapstructure:"benchPrompt"`
+    BenchTimeout   time.Duration `mapstructure:"benchTimeout"`
+    QuickBench     bool          `mapstructure:"quickBench"`
+    PromptLengths  []PromptLength `mapstructure:"promptLengths"`
+    // 按模型名称选用的提示词，未匹配时使用 benchPrompt
+    BenchPrompts   []ModelPrompt `mapstructure:"benchPrompts"`
+    // 中间文件配置
+    ScanOutputFile   string        `mapstructure:"scanOutputFile"`
+    OllamaOutputFile string        `mapstructure:"ollamaOutputFile"`
+    // 子网熔断配置
+    BreakerThreshold int           `mapstructure:"breakerThreshold"`
+    BreakerCooldown  time.Duration `mapstructure:"breakerCooldown"`
+    // 链路追踪配置
+    OtelEndpoint     string        `mapstructure:"otelEndpoint"`
+    // 模型列表解析失败重试配置
+    DecodeRetries    int           `mapstructure:"decodeRetries"`
+    DecodeRetryDelay time.Duration `mapstructure:"decodeRetryDelay"`
+    // 目标抽样配置
+    SampleStrategy   string        `mapstructure:"sampleStrategy"`
+    SampleSize       int           `mapstructure:"sampleSize"`
+    // 全局在途请求上限，0表示按文件描述符上限自动计算
+    MaxInFlight      int           `mapstructure:"maxInFlight"`
+    // 性能测试断点续测配置
+    Resume             bool          `mapstructure:"resume"`
+    CheckpointFile     string        `mapstructure:"checkpointFile"`
+    CheckpointInterval time.Duration `mapstructure:"checkpointInterval"`
+    // 单主机并发配置
+    MaxPerHost         int           `mapstructure:"maxPerHost"`
+    AdaptiveThrottle   bool          `mapstructure:"adaptiveThrottle"`
+    ThrottleWindow     int           `mapstructure:"throttleWindow"`
+    ThrottleTolerance  float64       `mapstructure:"throttleTolerance"`
+    // Kafka 输出配置
+    KafkaBrokers       []string      `mapstructure:"kafkaBrokers"`
+    KafkaTopic         string        `mapstructure:"kafkaTopic"`
+    // 阶段清单输出目录，为空表示不输出
+    ManifestDir        string        `mapstructure:"manifestDir"`
+    // 检测与性能测试流水线配置
+    Pipeline           bool          `mapstructure:"pipeline"`
+    PipelineBuffer     int           `mapstructure:"pipelineBuffer"`
+    // 扫描与检测流水线，全流程运行时扫描程序的输出直接交给检测协程
+    ScanPipeline       bool          `mapstructure:"scanPipeline"`
+    // 失败原因汇总文件，为空表示只打印不写文件
+    FailureSummaryFile string        `mapstructure:"failureSummaryFile"`
+    // 输出IP脱敏配置
+    RedactIP           string        `mapstructure:"redactIP"`
+    RedactSalt         string        `mapstructure:"redactSalt"`
+    // 健康检查服务监听地址，为空表示不启用
+    HealthAddr         string        `mapstructure:"healthAddr"`
+    // 目标列表预处理命令，通过 sh -c 执行，为空表示不处理
+    InputPreprocessor  string        `mapstructure:"inputPreprocessor"`
+    // 生成速度合理范围，超出时标记为可疑，0表示不检查对应边界
+    MinPlausibleTps    float64       `mapstructure:"minPlausibleTps"`
+    MaxPlausibleTps    float64       `mapstructure:"maxPlausibleTps"`
+    // 收到退出信号后等待在途任务完成的最长时间
+    DrainTimeout       time.Duration `mapstructure:"drainTimeout"`
+    // 检测时记录的响应头，用于识别代理或蜜罐
+    CaptureHeaders     []string      `mapstructure:"captureHeaders"`
+    // 重试等待时间是否加入随机抖动
+    RetryJitter        bool          `mapstructure:"retryJitter"`
+    // 模型目录输出配置，为空表示不输出
+    CatalogFile        string        `mapstructure:"catalogFile"`
+    CatalogExamples    int           `mapstructure:"catalogExamples"`
+    // 性能测试前预热模型，warmupWorkers 为预热工作池大小
+    Warmup             bool          `mapstructure:"warmup"`
+    WarmupWorkers      int           `mapstructure:"warmupWorkers"`
+    // 输出文件权限，八进制字符串
+    OutputFileMode     string        `mapstructure:"outputFileMode"`
+    // 单主机多路并发测试的路数，小于2表示不测试
+    MultiStreams       int           `mapstructure:"multiStreams"`
+    // 增量测试配置，跳过历史结果中速度超过阈值的组合
+    PriorResultsFile   string        `mapstructure:"priorResultsFile"`
+    SkipIfFasterThan   float64       `mapstructure:"skipIfFasterThan"`
+    // 历史检测结果文件，曾经响应过的IP优先探测
+    PriorDetectFile    string        `mapstructure:"priorDetectFile"`
+    // 效率评分配置，efficiencyFile 为空表示不输出
+    EfficiencyFile     string            `mapstructure:"efficiencyFile"`
+    EfficiencyWeights  EfficiencyWeights `mapstructure:"efficiencyWeights"`
+    HostCosts          []HostCost        `mapstructure:"hostCosts"`
+    DefaultCostPerHour float64           `mapstructure:"defaultCostPerHour"`
+    // 性能测试请求体使用gzip压缩
+    CompressRequests   bool          `mapstructure:"compressRequests"`
+    // HTTP探测失败时抓取TCP横幅
+    BannerGrab         bool          `mapstructure:"bannerGrab"`
+    BannerBytes        int           `mapstructure:"bannerBytes"`
+    BannerTimeout      time.Duration `mapstructure:"bannerTimeout"`
+    // 基线结果文件，输出相对基线的生成速度变化
+    BaselineFile       string        `mapstructure:"baselineFile"`
+    // 探测点标签，多地探测时区分结果来源
+    ProbeLabel         string        `mapstructure:"probeLabel"`
+    // 视为"服务存在但受限"的HTTP状态码
+    RestrictedStatuses []int         `mapstructure:"restrictedStatuses"`
+    // 按时间段调整扫描速率
+    RateSchedule       []RateWindow  `mapstructure:"rateSchedule"`
+    // 模型加载耗时超过该值视为冷启动，0表示不判断
+    ColdStartThreshold time.Duration `mapstructure:"coldStartThreshold"`
+    // 结果输出目标列表，每条结果同时发送到所有目标
+    Sinks              []SinkConfig  `mapstructure:"sinks"`
+    // 多次探测投票，probeVotes 为探测次数，voteThreshold 为需要一致的比例
+    ProbeVotes         int           `mapstructure:"probeVotes"`
+    VoteThreshold      float64       `mapstructure:"voteThreshold"`
+    // 按运行指标自动调整检测与测试并发数
+    Autoscale              bool          `mapstructure:"autoscale"`
+    AutoscaleMin           int           `mapstructure:"autoscaleMin"`
+    AutoscaleMax           int           `mapstructure:"autoscaleMax"`
+    AutoscaleInterval      time.Duration `mapstructure:"autoscaleInterval"`
+    AutoscaleMaxErrorRate  float64       `mapstructure:"autoscaleMaxErrorRate"`
+    AutoscaleMaxCPU        float64       `mapstructure:"autoscaleMaxCPU"`
+    AutoscaleMaxMemoryMB   int           `mapstructure:"autoscaleMaxMemoryMB"`
+    AutoscaleMaxGoroutines int           `mapstructure:"autoscaleMaxGoroutines"`
+    // 多端口扫描，逗号分隔的端口和范围，为空时只扫描 port
+    Ports              string        `mapstructure:"ports"`
+    // 扫描程序，zmap 或 masscan
+    Scanner            string        `mapstructure:"scanner"`
+    // 检测与测试结果文件格式，csv 或 jsonl
+    OutputFormat       string        `mapstructure:"outputFormat"`
+    // SQLite 结果库路径，为空时不写入
+    Database           string        `mapstructure:"database"`
+    // 服务检测断点文件，为空时不记录
+    DetectCheckpointFile string      `mapstructure:"detectCheckpointFile"`
+    // 获取模型列表遇到临时错误时的重试次数和首次退避时间，之后每次翻倍
+    RetryCount         int           `mapstructure:"retryCount"`
+    RetryBackoff       time.Duration `mapstructure:"retryBackoff"`
+    // 服务协议，http、https 或 auto（先尝试https再回退http）
+    Scheme             string        `mapstructure:"scheme"`
+    // 跳过TLS证书校验，用于自签名证书
+    InsecureSkipVerify bool          `mapstructure:"insecureSkipVerify"`
+    // 附加到所有请求的Bearer令牌，以及按主机区分的令牌文件
+    AuthToken          string        `mapstructure:"authToken"`
+    AuthTokensFile     string        `mapstructure:"authTokensFile"`
+    // 检测时请求 /api/ps，记录已加载到内存的模型及显存占用
+    ProbeRunning       bool          `mapstructure:"probeRunning"`
+    // 在测试结果中保存生成的文本，超过 saveResponseLength 个字符时截断
+    SaveResponse       bool          `mapstructure:"saveResponse"`
+    SaveResponseLength int           `mapstructure:"saveResponseLength"`
+    // 日志级别（debug、info、warn、error）、格式（text、json）和输出文件，文件为空时写入标准错误
+    LogLevel           string        `mapstructure:"logLevel"`
+    LogFormat          string        `mapstructure:"logFormat"`
+    LogFile            string        `mapstructure:"logFile"`
+    // 性能测试建立连接到收到响应头的超时，benchTimeout 为包括生成在内的总时长
+    BenchConnectTimeout time.Duration `mapstructure:"benchConnectTimeout"`
+    // Prometheus 指标服务监听地址，为空表示不启用
+    MetricsAddr        string        `mapstructure:"metricsAddr"`
+    // 发现关注模型时通知的 webhook 地址，以及模型名称关键词列表
+    WebhookURL         string        `mapstructure:"webhookURL"`
+    WatchModels        []string      `mapstructure:"watchModels"`
+    // 模型过滤通配符规则，排除优先，包含为空时保留全部
+    IncludeModels      []string      `mapstructure:"includeModels"`
+    ExcludeModels      []string      `mapstructure:"excludeModels"`
+    // 服务检测失败记录文件，为空表示不记录
+    ErrorFile          string        `mapstructure:"errorFile"`
+    // MaxMind 国家库和 ASN 库路径，配置后检测结果追加对应列
+    GeoIPDatabase      string        `mapstructure:"geoIPDatabase"`
+    GeoIPASNDatabase   string        `mapstructure:"geoIPASNDatabase"`
+    // 嵌入性能测试结果文件和每个模型的计时请求次数
+    EmbedOutputFile    string        `mapstructure:"embedOutputFile"`
+    EmbedRequests      int           `mapstructure:"embedRequests"`
+    // 性能测试使用的接口: generate 或 chat
+    BenchEndpoint      string        `mapstructure:"benchEndpoint"`
+    // 阶段汇总目录，为空表示只打印不写文件
+    SummaryDir         string        `mapstructure:"summaryDir"`
+    // 演练模式，只打印将要执行的命令和请求地址
+    DryRun             bool          `mapstructure:"dryRun"`
+    // 结果文件表头语言: zh 或 en
+    Language           string        `mapstructure:"language"`
+    // 服务检测每秒探测的目标数上限，0表示不限速
+    DetectRate         float64       `mapstructure:"detectRate"`
+    // 代理地址，支持 http://、https://、socks5://，为空表示直连
+    Proxy              string        `mapstructure:"proxy"`
+    // 性能测试清单，每行 ip,port,model，设置后代替服务检测结果作为测试输入
+    BenchTargetsFile   string        `mapstructure:"benchTargetsFile"`
+    // 每个模型的测试次数，大于1时首次作为预热丢弃，记录其余样本的中位数和P95
+    BenchRepeat        int           `mapstructure:"benchRepeat"`
+    // 模型列表接口不可用时探测 /v1/models 和 /health，识别 vLLM、LM Studio、llama.cpp 等服务
+    ProbeOtherServers  bool          `mapstructure:"probeOtherServers"`
+    // 服务检测和性能测试最多处理的目标数，0表示不限制
+    Limit              int           `mapstructure:"limit"`
+    // 打乱检测和内置扫描的目标顺序，把请求分散到不同网段
+    Shuffle            bool          `mapstructure:"shuffle"`
+    // 打乱顺序使用的随机种子，0表示每次运行随机生成
+    ShuffleSeed        int64         `mapstructure:"shuffleSeed"`
+    // 跳过历史结果中最近一次确定性失败（连接被拒绝、模型不存在、令牌被拒绝）的组合
+    SkipPreviousFailures bool        `mapstructure:"skipPreviousFailures"`
+    // 用于判断历史失败的结果文件，为空时使用 priorResultsFile
+    PreviousResultsFile string       `mapstructure:"previousResultsFile"`
+    // 请求使用的 User-Agent，为空时使用 scan/<版本号>
+    UserAgent          string        `mapstructure:"userAgent"`
+    // 附加到每个请求的自定义请求头，如认证代理需要的头
+    Headers            map[string]string `mapstructure:"headers"`
+    // 并行扫描任务列表
+    Jobs               []ScanJob     `mapstructure:"jobs"`
+}
+
+// 模型列表响应解析失败，通常是响应体被截断
+var errDecode = errors.New("模型列表解析失败")
+
+// 扫描器结构体
+type Scanner struct {
+    cfg        *Config
+    httpClient *http.Client
+    writer     resultWriter
+    csvFile    *partialFile
+    sharedFile *partialFile // 开启脱敏时的脱敏副本，未开启时为nil
+    outputFile string
+    mu         sync.Mutex
+    progress   *progress
+    breaker    *subnetBreaker
+    tracer     trace.Tracer
+    tracerProvider *sdktrace.TracerProvider
+    inflight   requestLimiter
+    throttle   *hostThrottle
+    sinks      multiSink
+    store      *resultStore
+    schemes    sync.Map // auto 模式下各目标检测到的协议
+    benchClient *http.Client // 性能测试与预热共用，总时长由每个请求的 context 控制
+    health     *healthState
+    dash       *dashboard
+    fileMode   os.FileMode
+    ports      []int
+    authTokens map[string]string
+    metrics    *scanMetrics
+    watch      *watchNotifier
+    includeModels []*regexp.Regexp
+    excludeModels []*regexp.Regexp
+    benchPrompts  []modelPrompt
+    geo        *geoLookup // 进程内只加载一次，各阶段共用
+    detectLimiter *rate.Limiter
+    baseURLFunc   BaseURLFunc
+    proxy         *url.URL // 为空表示直连
+    tlsConfig     *tls.Config
+    failureSummaryStarted bool // 本次运行已写入失败汇总，后续阶段追加而不是覆盖
+}
+
+// 使用给定配置创建扫描器，配置可以来自 LoadConfig、DefaultConfig 或直接构造，各实例之间互不共享状态
+func NewScanner(cfg *Config) (*Scanner, error) {
+    if err := cfg.Validate(); err != nil {
+        return nil, fmt.Errorf("配置无效: %w", err)
+    }
+    scanner := &Scanner{cfg: cfg}
+
+    // 以下取值已通过校验
+    mode, _ := strconv.ParseUint(cfg.OutputFileMode, 8, 32)
+    scanner.fileMode = os.FileMode(mode)
+    scanner.ports, _ = cfg.portList()
+    scanner.includeModels = compileGlobs(cfg.IncludeModels)
+    scanner.excludeModels = compileGlobs(cfg.ExcludeModels)
+    scanner.benchPrompts = compileModelPrompts(cfg.BenchPrompts)
+    tokens, err := loadAuthTokens(cfg.AuthTokensFile)
+    if err != nil {
+        return nil, fmt.Errorf("读取令牌文件失败: %w", err)
+    }
+    scanner.authTokens = tokens
+    if scanner.geo, err = openGeoLookup(cfg.GeoIPDatabase, cfg.GeoIPASNDatabase); err != nil {
+        return nil, err
+    }
+    
+    // 统一初始化HTTP客户端，自签名证书按配置跳过校验
+    tlsConfig := &tls.Config{InsecureSkipVerify: cfg.InsecureSkipVerify}
+    scanner.tlsConfig = tlsConfig
+    scanner.proxy, _ = parseProxy(cfg.Proxy)
+    if scanner.proxy != nil && cfg.Scanner != scannerNative {
+        slog.Warn("代理只作用于HTTP请求和原生扫描，端口扫描仍直接发出", "scanner", cfg.Scanner)
+    }
+    transport := &http.Transport{
+        MaxIdleConns:    cfg.MaxIdleConns,
+        IdleConnTimeout: cfg.IdleConnTimeout,
+        TLSClientConfig: tlsConfig,
+    }
+    benchTransport := http.DefaultTransport.(*http.Transport).Clone()
+    benchTransport.TLSClientConfig = tlsConfig
+    if scanner.proxy != nil {
+        transport.Proxy = http.ProxyURL(scanner.proxy)
+        benchTransport.Proxy = http.ProxyURL(scanner.proxy)
+    }
+    scanner.httpClient = &http.Client{Timeout: cfg.Timeout, Transport: newHeaderTransport(transport, cfg.UserAgent, cfg.Headers)}
+    scanner.benchClient = &http.Client{Transport: newHeaderTransport(benchTransport, cfg.UserAgent, cfg.Headers)}
+    scanner.breaker = newSubnetBreaker(cfg.BreakerThreshold, cfg.BreakerCooldown)
+    scanner.inflight = newRequestLimiter(cfg.MaxInFlight)
+    scanner.detectLimiter = newDetectLimiter(cfg.DetectRate)
+    scanner.throttle = newHostThrottle(cfg.MaxPerHost, cfg.AdaptiveThrottle, cfg.ThrottleWindow, cfg.ThrottleTolerance)
+
+    tracer, provider, err := newTracer(cfg.OtelEndpoint)
+    if err != nil {
+        return nil, err
+    }
+    scanner.tracer = tracer
+    scanner.tracerProvider = provider
+
+    scanner.health = &healthState{stage: "idle", lastActivity: time.Now()}
+    if cfg.HealthAddr != "" {
+        if err := scanner.startHealthServer(cfg.HealthAddr); err != nil {
+            return nil, err
+        }
+    }
+    if scanner.metrics, err = startMetricsServer(cfg.MetricsAddr); err != nil {
+        return nil, err
+    }
+    
+    return scanner, nil
+}
+
+// 结果全部写入后关闭结果文件，并从 .partial 重命名为正式文件
+func (s *Scanner) finishOutput() error {
+    if s.csvFile == nil {
+        return nil
+    }
+    s.writer.flush()
+    err := s.csvFile.close(true)
+    s.csvFile = nil
+    s.writer = nil
+    if err != nil {
+        return fmt.Errorf("关闭CSV文件失败: %w", err)
+    }
+    if s.sharedFile != nil {
+        err = s.sharedFile.close(true)
+        s.sharedFile = nil
+        if err != nil {
+            return fmt.Errorf("关闭脱敏结果文件失败: %w", err)
+        }
+    }
+    return nil
+}
+
+// 清理资源，未通过 finishOutput 完成的结果文件保留为 .partial
+func (s *Scanner) Close() error {
+    var err error
+    if s.csvFile != nil {
+        s.writer.flush()
+        if closeErr := s.csvFile.close(false); closeErr != nil {
+            err = fmt.Errorf("关闭CSV文件失败: %w", closeErr)
+        }
+        s.csvFile = nil
+        s.writer = nil
+    }
+    if s.sharedFile != nil {
+        if closeErr := s.sharedFile.close(false); closeErr != nil && err == nil {
+            err = fmt.Errorf("关闭脱敏结果文件失败: %w", closeErr)
+        }
+        s.sharedFile = nil
+    }
+    
+    // 关闭HTTP客户端连接池
+    if s.httpClient != nil {
+        s.httpClient.CloseIdleConnections()
+    }
+    if s.benchClient != nil {
+        s.benchClient.CloseIdleConnections()
+    }
+    
+    // 进度条资源清理
+    s.progress.finish()
+
+    // 发送剩余的外部输出
+    s.sinks.close()
+    s.sinks = nil
+    s.watch.close()
+    s.watch = nil
+    s.store.close()
+    s.store = nil
+
+    // 导出已缓存的追踪数据
+    if s.tracerProvider != nil {
+        s.tracerProvider.ForceFlush(context.Background())
+    }
+
+    return err
+}
+
+// 依次执行扫描、服务检测和性能测试，各阶段通过配置的中间文件衔接，任一阶段失败即停止
+// 开启 scanPipeline 时扫描与检测合并为边扫描边检测，开启 pipeline 时性能测试已随检测进行，不再单独执行
+func (s *Scanner) ScanAll(ctx context.Context) error {
+    type stage struct {
+        name string
+        run  func(context.Context) error
+    }
+    var stages []stage
+    if s.cfg.ScanPipeline {
+        stages = append(stages, stage{"scan_detect", s.ScanAndDetect})
+    } else {
+        stages = append(stages, stage{"scan", s.ScanIPs}, stage{"detect", s.DetectOllama})
+    }
+    if !s.cfg.Pipeline {
+        stages = append(stages, stage{"benchmark", s.BenchmarkOllama})
+    }
+    for _, stage := range stages {
+        if err := stage.run(ctx); err != nil {
+            return fmt.Errorf("%s 阶段失败: %w", stage.name, err)
+        }
+        // 阶段在中断后正常返回时不再开始下一阶段
+        if ctx.Err() != nil {
+            return errInterrupted
+        }
+    }
+    return nil
+}
+
+// 扫描IP地址
+func (s *Scanner) ScanIPs(ctx context.Context) (err error) {
+    ctx, span := s.tracer.Start(ctx, "scan")
+    defer func() { endSpan(span, err) }()
+    if s.cfg.DryRun {
+        return s.dryRunScan(ctx)
+    }
+    s.health.setStage("scan", 0)
+    defer s.health.setStage("idle", 0)
+
+    manifest := newStageManifest("scan",
+        []string{s.cfg.InputFile},
+        []string{s.cfg.ScanOutputFile})
+    s.dash.watch(manifest)
+    defer func() { s.writeManifest(manifest, err) }()
+
+    if err := s.checkScanner(); err != nil {
+        return err
+    }
+    input, cleanup, err := s.scanInput()
+    if err != nil {
+        return fmt.Errorf("预处理输入文件失败: %w", err)
+    }
+    defer cleanup()
+
+    if s.cfg.Scanner == scannerNative {
+        return s.nativeScanFile(ctx, input, manifest)
+    }
+
+    // masscan 输出为列表格式，压缩的结果文件扫描程序无法直接写入，都先写入临时文件，扫描结束后转换为目标列表
+    output := s.cfg.ScanOutputFile
+    if s.cfg.Scanner == scannerMasscan || isGzip(output) {
+        raw, err := os.CreateTemp("", "scan-output-*.txt")
+        if err != nil {
+            return fmt.Errorf("创建临时文件失败: %w", err)
+        }
+        raw.Close()
+        defer os.Remove(raw.Name())
+        output = raw.Name()
+    }
+
+    cmd := s.scanCommand(ctx, input, output)
+    cmd.Stdout = s.dash.output(os.Stdout)
+    cmd.Stderr = s.dash.output(os.Stderr)
+
+    // 执行扫描命令，收到退出信号时扫描程序已写入的结果会保留
+    if err := cmd.Run(); err != nil {
+        if ctx.Err() != nil {
+            return errInterrupted
+        }
+        return fmt.Errorf("%s执行失败: %w", s.cfg.Scanner, err)
+    }
+    if output != s.cfg.ScanOutputFile {
+        if err := s.normalizeScanOutput(output, s.cfg.ScanOutputFile); err != nil {
+            return fmt.Errorf("转换扫描结果失败: %w", err)
+        }
+    }
+
+    // 统计发现的主机数
+    if file, err := openRead(s.cfg.ScanOutputFile); err == nil {
+        lines := bufio.NewScanner(file)
+        for lines.Scan() {
+            if strings.TrimSpace(lines.Text()) != "" {
+                manifest.add("hosts", 1)
+            }
+        }
+        file.Close()
+    }
+
+    return nil
+}
+
+// 构建 zmap 命令，output 为 "-" 时结果输出到标准输出
+func (s *Scanner) zmapCommand(ctx context.Context, input, output string) *exec.Cmd {
+    rate, bandwidth := s.scheduledRate(time.Now())
+    args := []string{"zmap",
+        "-w", input,
+        "-o", output,
+        "-p", s.portSpec(),
+        "--rate", strconv.Itoa(rate),
+        "-B", bandwidth,
+    }
+    // 多端口扫描时输出 "IP,端口"，检测阶段据此探测实际开放的端口
+    if len(s.ports) > 1 {
+        args = append(args,
+            "-O", "csv",
+            "-f", "saddr,sport",
+            "--output-filter", "success = 1 && repeat = 0",
+            "--no-header-row",
+        )
+    }
+    cmd := exec.CommandContext(ctx, "sudo", args...)
+    
+    // 打印完整命令
+    slog.Info("执行命令", "command", strings.Join(cmd.Args, " "))
+    return cmd
+}
+
+// 边扫描边检测，扫描程序每发现一个主机立即交给检测协程
+func (s *Scanner) ScanAndDetect(ctx context.Context) (err error) {
+    ctx, span := s.tracer.Start(ctx, "scan_detect")
+    defer func() { endSpan(span, err) }()
+    if s.cfg.DryRun {
+        return s.dryRunScan(ctx)
+    }
+    s.health.setStage("detect", 0)
+    defer s.health.setStage("idle", 0)
+
+    scanManifest := newStageManifest("scan",
+        []string{s.cfg.InputFile},
+        []string{s.cfg.ScanOutputFile})
+    detectManifest := newStageManifest("detect",
+        []string{s.cfg.ScanOutputFile},
+        []string{s.cfg.OllamaOutputFile})
+    s.dash.watch(scanManifest)
+    s.dash.watch(detectManifest)
+    var scanErr error
+    defer func() {
+        s.writeManifest(scanManifest, scanErr)
+        s.writeManifest(detectManifest, err)
+    }()
+
+    // 扫描结果同时写入文件，便于后续单独重跑检测
+    scanFile, err := createOutput(s.cfg.ScanOutputFile, s.fileMode)
+    if err != nil {
+        return fmt.Errorf("创建扫描结果文件失败: %w", err)
+    }
+    defer scanFile.Close()
+
+    if err := s.checkScanner(); err != nil {
+        return err
+    }
+    input, cleanup, err := s.scanInput()
+    if err != nil {
+        return fmt.Errorf("预处理输入文件失败: %w", err)
+    }
+    defer cleanup()
+
+    hosts := make(chan string, s.cfg.PipelineBuffer)
+    found := func(ip string) {
+        fmt.Fprintln(scanFile, ip)
+        scanManifest.add("hosts", 1)
+        hosts <- ip
+    }
+
+    // 原生扫描在进程内运行，检测失败时取消剩余连接
+    if s.cfg.Scanner == scannerNative {
+        scanCtx, cancelScan := context.WithCancel(ctx)
+        defer cancelScan()
+        scanDone := make(chan error, 1)
+        go func() {
+            defer close(hosts)
+            scanDone <- s.nativeScan(scanCtx, input, found)
+        }()
+        detectErr := s.detect(ctx, detectManifest, hosts, 0, nil)
+        if detectErr != nil {
+            cancelScan()
+        }
+        if err := <-scanDone; err != nil && detectErr == nil {
+            scanErr = fmt.Errorf("原生扫描失败: %w", err)
+            return scanErr
+        }
+        return detectErr
+    }
+
+    cmd := s.scanCommand(ctx, input, "-")
+    cmd.Stderr = s.dash.output(os.Stderr)
+    stdout, err := cmd.StdoutPipe()
+    if err != nil {
+        return fmt.Errorf("创建%s输出管道失败: %w", s.cfg.Scanner, err)
+    }
+    if err := cmd.Start(); err != nil {
+        return fmt.Errorf("%s启动失败: %w", s.cfg.Scanner, err)
+    }
+
+    go func() {
+        defer close(hosts)
+        reader := bufio.NewScanner(stdout)
+        for reader.Scan() {
+            if ip, ok := s.scanLine(reader.Text()); ok {
+                found(ip)
+            }
+        }
+    }()
+
+    detectErr := s.detect(ctx, detectManifest, hosts, 0, nil)
+    if detectErr != nil {
+        // 检测失败时不再需要扫描结果
+        cmd.Process.Kill()
+    }
+    if err := cmd.Wait(); err != nil && detectErr == nil {
+        scanErr = fmt.Errorf("%s执行失败: %w", s.cfg.Scanner, err)
+        return scanErr
+    }
+    return detectErr
+}
+
+// /api/tags 返回的模型信息
+type ModelInfo struct {
+    Name              string    `json:"name"`
+    Size              int64     `json:"size"`
+    ParameterSize     string    `json:"parameter_size"`
+    QuantizationLevel string    `json:"quantization_level"`
+    ModifiedAt        time.Time `json:"modified_at"`
+    Family            string    `json:"family"`
+}
+
+// 模型名称列表
+func modelNames(models []ModelInfo) []string {
+    names := make([]string, len(models))
+    for i, m := range models {
+        names[i] = m.Name
+    }
+    return names
+}
+
+// 获取模型信息，解析失败时按 decodeRetries 重试，
+// 超时、连接重置和5xx等临时错误按 retryCount 以指数退避重试
+func (s *Scanner) getModels(ctx context.Context, ip string, port int) ([]ModelInfo, map[string]string, error) {
+    var headers map[string]string
+    decodeRetries, transientRetries := 0, 0
+    for {
+        models, resp, err := s.fetchModels(ctx, ip, port)
+        if resp != nil {
+            headers = s.captureHeaders(resp.Header)
+        }
+
+        var delay time.Duration
+        switch {
+        case errors.Is(err, errDecode) && decodeRetries < s.cfg.DecodeRetries:
+            decodeRetries++
+            delay = s.cfg.DecodeRetryDelay
+        case transientError(err) && transientRetries < s.cfg.RetryCount:
+            delay = s.cfg.RetryBackoff << transientRetries
+            transientRetries++
+        default:
+            return models, headers, err
+        }
+        if !sleepContext(ctx, s.retryDelay(delay)) {
+            return nil, headers, err
+        }
+    }
+}
+
+// 提取配置的响应头，未配置时返回nil
+func (s *Scanner) captureHeaders(header http.Header) map[string]string {
+    if len(s.cfg.CaptureHeaders) == 0 {
+        return nil
+    }
+    captured := make(map[string]string, len(s.cfg.CaptureHeaders))
+    for _, name := range s.cfg.CaptureHeaders {
+        captured[name] = strings.Join(header.Values(name), "; ")
+    }
+    return captured
+}
+
+// 使用指定协议单次请求模型列表，连接失败、非200响应或解析失败时返回错误
+func (s *Scanner) fetchModelsWith(ctx context.Context, scheme, ip string, port int) ([]ModelInfo, *http.Response, error) {
+    if err := s.inflight.acquire(ctx); err != nil {
+        return nil, nil, err
+    }
+    defer s.inflight.release()
+
+    var models []ModelInfo
+    req, err := http.NewRequestWithContext(ctx, "GET", s.baseURLWith(scheme, ip, port)+"/api/tags", nil)
+    if err != nil {
+        return models, nil, err
+    }
+    s.authorize(req, ip, port)
+    modelsResp, err := s.httpClient.Do(req)
+    if err != nil {
+        return models, nil, err
+    }
+    if modelsResp.StatusCode != http.StatusOK {
+        modelsResp.Body.Close()
+        return models, modelsResp, &statusError{code: modelsResp.StatusCode}
+    }
+    defer modelsResp.Body.Close()
+    var data struct {
+        Models []struct {
+            Name       string    `json:"name"`
+            Size       int64     `json:"size"`
+            ModifiedAt time.Time `json:"modified_at"`
+            Details    struct {
+                ParameterSize     string `json:"parameter_size"`
+                QuantizationLevel string `json:"quantization_level"`
+                Family            string `json:"family"`
+            } `json:"details"`
+        } `json:"models"`
+    }
+    
+    if err := json.NewDecoder(modelsResp.Body).Decode(&data); err != nil {
+        return nil, modelsResp, fmt.Errorf("%w: %v", errDecode, err)
+    }
+    for _, m := range data.Models {
+        models = append(models, ModelInfo{
+            Name:              m.Name,
+            Size:              m.Size,
+            ParameterSize:     m.Details.ParameterSize,
+            QuantizationLevel: m.Details.QuantizationLevel,
+            ModifiedAt:        m.ModifiedAt,
+            Family:            m.Details.Family,
+        })
+    }
+    return models, modelsResp, nil
+}
+
+// 服务检测
+func (s *Scanner) DetectOllama(ctx context.Context) (err error) {
+    ctx, span := s.tracer.Start(ctx, "detect")
+    defer func() { endSpan(span, err) }()
+    if s.cfg.DryRun {
+        return s.dryRunDetect(ctx)
+    }
+
+    manifest := newStageManifest("detect",
+        []string{s.cfg.ScanOutputFile},
+        []string{s.cfg.OllamaOutputFile})
+    s.dash.watch(manifest)
+    defer func() { s.writeManifest(manifest, err) }()
+
+    // 抽样、打乱顺序和历史主机优先需要看到全部目标，其余情况边读边探测，避免大文件整个读入内存
+    if s.cfg.SampleSize > 0 || s.cfg.Shuffle || s.cfg.PriorDetectFile != "" {
+        return s.detectLoaded(ctx, manifest)
+    }
+    return s.detectStream(ctx, manifest)
+}
+
+// 读入全部目标后抽样、打乱或按历史结果排序，再展开探测
+func (s *Scanner) detectLoaded(ctx context.Context, manifest *stageManifest) error {
+    targets, err := s.openTargets(s.cfg.ScanOutputFile)
+    if err != nil {
+        return fmt.Errorf("读取IP文件失败: %w", err)
+    }
+    var ips []string
+    if s.cfg.SampleSize > 0 {
+        // 只探测抽样得到的目标
+        ips, err = sampleTargets(targets, s.cfg.SampleStrategy, s.cfg.SampleSize)
+        if err != nil {
+            targets.Close()
+            return fmt.Errorf("抽样目标失败: %w", err)
+        }
+        slog.Info("抽样目标", "strategy", s.cfg.SampleStrategy, "count", len(ips))
+    } else {
+        ipsData, err := io.ReadAll(targets)
+        if err != nil {
+            targets.Close()
+            return fmt.Errorf("读取IP文件失败: %w", err)
+        }
+        ips = strings.Split(string(ipsData), "\n")
+    }
+    if err := targets.Close(); err != nil {
+        return err
+    }
+    ips = dedupeTargets(ips)
+    if len(ips) == 0 {
+        return fmt.Errorf("未找到有效IP地址")
+    }
+
+    // 打乱在历史排序之前，历史主机仍排在前面，两组内部各自随机
+    if s.cfg.Shuffle {
+        seed := shuffleTargets(ips, s.cfg.ShuffleSeed)
+        slog.Info("已打乱检测目标顺序", "count", len(ips), "seed", seed)
+    }
+
+    // 优先探测历史上响应过的主机，让发现尽早出现
+    if s.cfg.PriorDetectFile != "" {
+        known, err := loadPriorHosts(s.cfg.PriorDetectFile, s.cfg.OutputFormat)
+        if err != nil {
+            return fmt.Errorf("读取历史检测结果失败: %w", err)
+        }
+        slog.Info("历史响应主机优先探测", "count", prioritizeHosts(ips, known))
+    }
+
+    cp, err := s.loadDetectCheckpoint()
+    if err != nil {
+        return fmt.Errorf("读取检测断点失败: %w", err)
+    }
+
+    // 网段边探测边展开，避免大网段占满内存
+    total := s.countTargets(ips)
+    s.health.setStage("detect", total)
+    defer s.health.setStage("idle", 0)
+
+    hosts := make(chan string)
+    streamCtx, stopStream := context.WithCancel(ctx)
+    defer stopStream()
+    go func() {
+        defer close(hosts)
+        s.streamTargets(streamCtx, ips, hosts)
+    }()
+    return s.detect(ctx, manifest, hosts, total, cp)
+}
+
+// 逐行读取目标并交给探测协程，普通文件先快速数一遍得到进度总数，
+// 标准输入和预处理命令的输出只能读一次，总数未知
+func (s *Scanner) detectStream(ctx context.Context, manifest *stageManifest) error {
+    total := 0
+    if s.cfg.ScanOutputFile != stdinPath && s.cfg.InputPreprocessor == "" {
+        file, err := openRead(s.cfg.ScanOutputFile)
+        if err != nil {
+            return fmt.Errorf("读取IP文件失败: %w", err)
+        }
+        total, err = s.countReader(file)
+        file.Close()
+        if err != nil {
+            return fmt.Errorf("读取IP文件失败: %w", err)
+        }
+        if total == 0 {
+            return fmt.Errorf("未找到有效IP地址")
+        }
+    }
+
+    cp, err := s.loadDetectCheckpoint()
+    if err != nil {
+        return fmt.Errorf("读取检测断点失败: %w", err)
+    }
+    targets, err := s.openTargets(s.cfg.ScanOutputFile)
+    if err != nil {
+        return fmt.Errorf("读取IP文件失败: %w", err)
+    }
+    s.health.setStage("detect", total)
+    defer s.health.setStage("idle", 0)
+
+    hosts := make(chan string)
+    streamCtx, stopStream := context.WithCancel(ctx)
+    defer stopStream()
+    readDone := make(chan error, 1)
+    go func() {
+        defer close(hosts)
+        readDone <- s.streamReader(streamCtx, targets, hosts)
+    }()
+    if err := s.detect(ctx, manifest, hosts, total, cp); err != nil {
+        targets.Close()
+        return err
+    }
+    // 达到 limit 时输入没有读完，停止读取
+    stopStream()
+    if err := <-readDone; err != nil {
+        targets.Close()
+        return fmt.Errorf("读取IP文件失败: %w", err)
+    }
+    if err := targets.Close(); err != nil && s.cfg.Limit == 0 {
+        // 提前停止读取时预处理命令会因管道关闭而退出，不算失败
+        return err
+    }
+    return nil
+}
+
+// 性能测试
+func (s *Scanner) BenchmarkOllama(ctx context.Context) (err error) {
+    ctx, span := s.tracer.Start(ctx, "benchmark")
+    defer func() { endSpan(span, err) }()
+    if s.cfg.DryRun {
+        return s.dryRunBench()
+    }
+
+    manifest := newStageManifest("benchmark",
+        []string{s.benchInput()},
+        []string{s.cfg.OutputFile})
+    s.dash.watch(manifest)
+    defer func() { s.writeManifest(manifest, err) }()
+
+    defer s.Close()
+    s.sinks = s.newSinks()
+    if s.store, err = s.openStore(); err != nil {
+        return err
+    }
+    
+    // 读取服务检测结果或测试清单
+    targets, err := s.loadBenchTargets()
+    if err != nil {
+        return err
+    }
+    validRecords := len(targets)
+
+    run, err := s.newBenchRun(ctx, manifest, s.benchInput())
+    if err != nil {
+        return err
+    }
+
+    // 续测时进度从断点中已完成的数量开始，反映真实的整体完成度
+    resumed := run.resumedCount(targets)
+    s.health.setStage("benchmark", validRecords)
+    s.health.advance(resumed)
+    defer s.health.setStage("idle", 0)
+
+    run.progress = s.newProgress("测试进度", validRecords) // 使用实际有效记录数
+    run.progress.setCurrent(resumed)
+    run.progress.start()
+
+    if s.cfg.Warmup {
+        slog.Info("预热模型", "count", len(targets))
+        run.warmAll(targets)
+    }
+
+    for _, target := range targets {
+        if run.drain.stopped() {
+            break
+        }
+        run.submit(target.ip, target.port, target.model)
+    }
+    
+    return run.finish()
+}
+
+// 执行单个阶段，面板模式下阶段运行期间的输出都收进面板
+// 阶段运行期间收到 Ctrl+C 或 SIGTERM 时取消 ctx，各阶段停止派发新任务并保存已有结果
+func (s *Scanner) RunStage(stage func(context.Context) error) error {
+    ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+    defer stop()
+    s.dash.start()
+    defer s.dash.stop()
+    return stage(ctx)
+}
@@ -0,0 +1,380 @@
+package scan
+
+import (
+    "context"
+    "errors"
+    "fmt"
+    "math"
+    "net"
+    "net/http"
+    "net/http/httptest"
+    "os"
+    "path/filepath"
+    "slices"
+    "strconv"
+    "sync"
+    "testing"
+    "time"
+)
+
+// 启动模拟的 Ollama 服务，返回供 getModels、benchmarkModel 使用的IP和端口
+func fakeOllama(t *testing.T, mux *http.ServeMux) (string, int) {
+    t.Helper()
+    server := httptest.NewServer(mux)
+    t.Cleanup(server.Close)
+    host, portText, err := net.SplitHostPort(server.Listener.Addr().String())
+    if err != nil {
+        t.Fatal(err)
+    }
+    port, _ := strconv.Atoi(portText)
+    return host, port
+}
+
+// 使用默认配置创建扫描器，configure 可以修改配置
+func newTestScanner(t *testing.T, configure func(*Config)) *Scanner {
+    t.Helper()
+    cfg := DefaultConfig()
+    // 测试中不等待重试
+    cfg.DecodeRetries = 0
+    if configure != nil {
+        configure(cfg)
+    }
+    s, err := NewScanner(cfg)
+    if err != nil {
+        t.Fatal(err)
+    }
+    t.Cleanup(func() { s.Close() })
+    return s
+}
+
+// 模拟服务的模型列表
+const tagsResponse = `{"models":[
+    {"name":"llama3:8b","size":4661224676,"modified_at":"2024-05-01T00:00:00Z",
+     "details":{"parameter_size":"8.0B","quantization_level":"Q4_0","family":"llama"}},
+    {"name":"nomic-embed-text:latest","size":274302450,
+     "details":{"parameter_size":"137M","quantization_level":"F16","family":"nomic-bert"}}]}`
+
+func TestGetModels(t *testing.T) {
+    mux := http.NewServeMux()
+    mux.HandleFunc("/api/tags", func(w http.ResponseWriter, r *http.Request) {
+        w.Header().Set("Server", "ollama-test")
+        fmt.Fprint(w, tagsResponse)
+    })
+    ip, port := fakeOllama(t, mux)
+    s := newTestScanner(t, func(cfg *Config) {
+        cfg.CaptureHeaders = []string{"Server"}
+    })
+
+    models, headers, err := s.getModels(context.Background(), ip, port)
+    if err != nil {
+        t.Fatalf("getModels 返回错误: %v", err)
+    }
+    if len(models) != 2 {
+        t.Fatalf("模型数为 %d，应为 2", len(models))
+    }
+    llama := models[0]
+    if llama.Name != "llama3:8b" || llama.Size != 4661224676 || llama.ParameterSize != "8.0B" ||
+        llama.QuantizationLevel != "Q4_0" || llama.ModifiedAt.Year() != 2024 {
+        t.Errorf("模型信息解析错误: %+v", llama)
+    }
+    if llama.embeddingModel() || !models[1].embeddingModel() {
+        t.Errorf("嵌入模型识别错误: %+v", models)
+    }
+    if headers["Server"] != "ollama-test" {
+        t.Errorf("响应头为 %v，应包含 Server: ollama-test", headers)
+    }
+}
+
+func TestGetModelsErrors(t *testing.T) {
+    tests := []struct {
+        name    string
+        handler http.HandlerFunc
+        check   func(error) bool
+    }{
+        {
+            name: "非200响应",
+            handler: func(w http.ResponseWriter, r *http.Request) {
+                http.NotFound(w, r)
+            },
+            check: func(err error) bool {
+                var se *statusError
+                return errors.As(err, &se) && se.code == http.StatusNotFound
+            },
+        },
+        {
+            name: "响应体截断",
+            handler: func(w http.ResponseWriter, r *http.Request) {
+                fmt.Fprint(w, `{"models":[{"name":"llama3`)
+            },
+            check: func(err error) bool {
+                return errors.Is(err, errDecode)
+            },
+        },
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            mux := http.NewServeMux()
+            mux.HandleFunc("/api/tags", tt.handler)
+            ip, port := fakeOllama(t, mux)
+            s := newTestScanner(t, nil)
+
+            _, _, err := s.getModels(context.Background(), ip, port)
+            if !tt.check(err) {
+                t.Errorf("错误为 %v", err)
+            }
+        })
+    }
+}
+
+func TestGetModelsConnectionRefused(t *testing.T) {
+    // 取一个空闲端口后关闭，连接会被拒绝
+    listener, err := net.Listen("tcp", "127.0.0.1:0")
+    if err != nil {
+        t.Fatal(err)
+    }
+    port := listener.Addr().(*net.TCPAddr).Port
+    listener.Close()
+    s := newTestScanner(t, nil)
+
+    _, _, err = s.getModels(context.Background(), "127.0.0.1", port)
+    if classifyError(err) != "连接被拒绝" {
+        t.Errorf("失败原因为 %q（%v），应为连接被拒绝", classifyError(err), err)
+    }
+}
+
+func TestSetBaseURL(t *testing.T) {
+    var auth string
+    mux := http.NewServeMux()
+    mux.HandleFunc("/api/tags", func(w http.ResponseWriter, r *http.Request) {
+        auth = r.Header.Get("Authorization")
+        fmt.Fprint(w, tagsResponse)
+    })
+    server := httptest.NewServer(mux)
+    t.Cleanup(server.Close)
+    s := newTestScanner(t, nil)
+    s.authTokens = map[string]string{"203.0.113.1": "target"}
+    // 目标地址不可达，请求全部改道到模拟服务
+    var gotIP string
+    var gotPort int
+    s.SetBaseURL(func(scheme, ip string, port int) string {
+        gotIP, gotPort = ip, port
+        return server.URL
+    })
+
+    models, _, err := s.getModels(context.Background(), "203.0.113.1", 11434)
+    if err != nil || len(models) != 2 {
+        t.Fatalf("getModels 返回 %d 个模型，错误: %v", len(models), err)
+    }
+    if gotIP != "203.0.113.1" || gotPort != 11434 {
+        t.Errorf("BaseURLFunc 收到 %s:%d", gotIP, gotPort)
+    }
+    // 令牌按目标而不是改道后的地址查找
+    if auth != "Bearer target" {
+        t.Errorf("认证头为 %q，应使用目标的令牌", auth)
+    }
+    if got := s.serviceURL("203.0.113.1", 11434, "/api/ps"); got != server.URL+"/api/ps" {
+        t.Errorf("接口地址为 %s", got)
+    }
+}
+
+func TestShuffleTargets(t *testing.T) {
+    targets := []string{"10.0.0.1", "10.0.0.2", "10.0.1.1", "10.0.1.2", "10.0.2.1", "10.0.2.2"}
+    first := slices.Clone(targets)
+    second := slices.Clone(targets)
+    if seed := shuffleTargets(first, 42); seed != 42 {
+        t.Fatalf("种子应为 42，实际为 %d", seed)
+    }
+    shuffleTargets(second, 42)
+    if !slices.Equal(first, second) {
+        t.Errorf("相同种子得到不同顺序: %v 与 %v", first, second)
+    }
+    slices.Sort(first)
+    if !slices.Equal(first, targets) {
+        t.Errorf("打乱后目标集合改变: %v", first)
+    }
+
+    if seed := shuffleTargets(slices.Clone(targets), 0); seed == 0 {
+        t.Error("种子为0时应生成新的种子")
+    }
+}
+
+func TestRequestHeaders(t *testing.T) {
+    var mu sync.Mutex
+    seen := make(map[string]http.Header)
+    record := func(w http.ResponseWriter, r *http.Request) {
+        mu.Lock()
+        seen[r.URL.Path] = r.Header.Clone()
+        mu.Unlock()
+    }
+    mux := http.NewServeMux()
+    mux.HandleFunc("/api/tags", func(w http.ResponseWriter, r *http.Request) {
+        record(w, r)
+        fmt.Fprint(w, tagsResponse)
+    })
+    mux.HandleFunc("/api/generate", func(w http.ResponseWriter, r *http.Request) {
+        record(w, r)
+        streamHandler(0, `{"response":"hi","done":true,"eval_count":1,"eval_duration":1000000}`)(w, r)
+    })
+    ip, port := fakeOllama(t, mux)
+
+    // 默认 User-Agent 带版本号
+    s := newTestScanner(t, nil)
+    if _, _, err := s.getModels(context.Background(), ip, port); err != nil {
+        t.Fatal(err)
+    }
+    if got := seen["/api/tags"].Get("User-Agent"); got != "scan/"+Version {
+        t.Errorf("默认 User-Agent 为 %q", got)
+    }
+
+    s = newTestScanner(t, func(cfg *Config) {
+        cfg.UserAgent = "scan-research (security@example.com)"
+        cfg.AuthToken = "secret"
+        cfg.Headers = map[string]string{"x-scan-contact": "security@example.com", "Authorization": "Bearer proxy"}
+    })
+    if _, _, err := s.getModels(context.Background(), ip, port); err != nil {
+        t.Fatal(err)
+    }
+    if result := s.benchmarkModel(context.Background(), ip, port, "llama3:8b", "hi"); result.Status != "成功" {
+        t.Fatalf("状态为 %q，应为成功", result.Status)
+    }
+    for _, path := range []string{"/api/tags", "/api/generate"} {
+        header := seen[path]
+        if got := header.Get("User-Agent"); got != "scan-research (security@example.com)" {
+            t.Errorf("%s 的 User-Agent 为 %q", path, got)
+        }
+        if got := header.Get("X-Scan-Contact"); got != "security@example.com" {
+            t.Errorf("%s 缺少自定义请求头，实际为 %q", path, got)
+        }
+        // 按目标附加的令牌优先于自定义请求头
+        if got := header.Get("Authorization"); got != "Bearer secret" {
+            t.Errorf("%s 的认证头为 %q", path, got)
+        }
+    }
+}
+
+func TestValidateDurations(t *testing.T) {
+    tests := []struct {
+        name      string
+        configure func(*Config)
+    }{
+        {"checkpointInterval", func(c *Config) { c.CheckpointInterval = 0 }},
+        {"autoscaleInterval", func(c *Config) { c.AutoscaleInterval = 0 }},
+        {"breakerCooldown", func(c *Config) { c.BreakerCooldown = -time.Second }},
+        {"decodeRetryDelay", func(c *Config) { c.DecodeRetryDelay = -time.Second }},
+        {"retryBackoff", func(c *Config) { c.RetryBackoff = -time.Second }},
+        {"drainTimeout", func(c *Config) { c.DrainTimeout = -time.Second }},
+        {"bannerTimeout", func(c *Config) { c.BannerTimeout = 0 }},
+        {"throttleWindow", func(c *Config) { c.ThrottleWindow = 0 }},
+        {"sampleStrategy", func(c *Config) { c.SampleStrategy = "random" }},
+        {"redactIP", func(c *Config) { c.RedactIP = "partial" }},
+    }
+    for _, tt := range tests {
+        cfg := DefaultConfig()
+        tt.configure(cfg)
+        if err := cfg.Validate(); err == nil {
+            t.Errorf("%s 取值无效时应返回错误", tt.name)
+        }
+    }
+    if err := DefaultConfig().Validate(); err != nil {
+        t.Errorf("默认配置校验失败: %v", err)
+    }
+}
+
+func TestSinglePortList(t *testing.T) {
+    // ports 只有一个端口时以它为准，不回落到 port
+    s := newTestScanner(t, func(cfg *Config) { cfg.Ports = "8080" })
+    if got := s.portSpec(); got != "8080" {
+        t.Errorf("扫描端口为 %s，应为 8080", got)
+    }
+    if _, port := splitTarget("10.0.0.1", s.defaultPort()); port != 8080 {
+        t.Errorf("探测端口为 %d，应为 8080", port)
+    }
+}
+
+func TestAcquireCanceled(t *testing.T) {
+    ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+    defer cancel()
+
+    limiter := newRequestLimiter(1)
+    if err := limiter.acquire(ctx); err != nil {
+        t.Fatal(err)
+    }
+    if err := limiter.acquire(ctx); !errors.Is(err, context.DeadlineExceeded) {
+        t.Errorf("名额已满时应在 ctx 超时后返回，实际为 %v", err)
+    }
+
+    throttle := newHostThrottle(1, false, 0, 0)
+    if err := throttle.acquire(context.Background(), "10.0.0.1"); err != nil {
+        t.Fatal(err)
+    }
+    if err := throttle.acquire(ctx, "10.0.0.1"); !errors.Is(err, context.DeadlineExceeded) {
+        t.Errorf("主机名额已满时应在 ctx 超时后返回，实际为 %v", err)
+    }
+    // 取消的等待不占用名额，释放后可以再次获取
+    throttle.release("10.0.0.1", 0)
+    if err := throttle.acquire(context.Background(), "10.0.0.1"); err != nil {
+        t.Errorf("释放后获取名额失败: %v", err)
+    }
+}
+
+func TestRedactedPath(t *testing.T) {
+    tests := map[string]string{
+        "ollama.csv":        "ollama.redacted.csv",
+        "out/results.jsonl": "out/results.redacted.jsonl",
+        "results.csv.gz":    "results.redacted.csv.gz",
+        "results":           "results.redacted",
+    }
+    for path, want := range tests {
+        if got := redactedPath(path); got != want {
+            t.Errorf("%s 的脱敏副本路径为 %s，应为 %s", path, got, want)
+        }
+    }
+}
+
+func TestValidateRedact(t *testing.T) {
+    for _, mode := range []string{"", "partial", "hash"} {
+        cfg := DefaultConfig()
+        cfg.RedactIP = mode
+        if err := cfg.Validate(); err == nil {
+            t.Errorf("redactIP 为 %q 且未设置盐值时应返回错误", mode)
+        }
+    }
+    cfg := DefaultConfig()
+    cfg.RedactIP = redactHash
+    cfg.RedactSalt = "salt"
+    if err := cfg.Validate(); err != nil {
+        t.Errorf("设置盐值后校验失败: %v", err)
+    }
+}
+
+func TestReportFailuresAppendsStages(t *testing.T) {
+    path := filepath.Join(t.TempDir(), "failures.csv")
+    s := newTestScanner(t, func(cfg *Config) { cfg.FailureSummaryFile = path })
+
+    detect := newFailureStats()
+    detect.add("超时")
+    s.reportFailures("detect", detect)
+    bench := newFailureStats()
+    bench.add("非200")
+    s.reportFailures("benchmark", bench)
+
+    data, err := os.ReadFile(path)
+    if err != nil {
+        t.Fatal(err)
+    }
+    want := "阶段,失败原因,数量\ndetect,超时,1\nbenchmark,非200,1\n"
+    if string(data) != want {
+        t.Errorf("失败汇总为 %q，应为 %q", data, want)
+    }
+}
+
+func TestCountTargetsSaturates(t *testing.T) {
+    s := newTestScanner(t, func(cfg *Config) { cfg.Ports = "11434,8000" })
+    // 超大IPv6网段乘以端口数或与其他行相加都不能溢出为负数
+    if got := s.countTargets([]string{"2001:db8::/32", "10.0.0.0/30"}); got != math.MaxInt {
+        t.Errorf("目标数为 %d，应为 math.MaxInt", got)
+    }
+    if got := s.countTargets([]string{"10.0.0.0/30", "10.0.0.9,8080"}); got != 9 {
+        t.Errorf("目标数为 %d，应为 9", got)
+    }
+}
@@ -0,0 +1,67 @@
+package scan
+
+import (
+    "fmt"
+    "log/slog"
+    "time"
+)
+
+// 扫描速率时间段，start/end 为 "HH:MM" 格式的本地时间，end 早于 start 表示跨越午夜
+type RateWindow struct {
+    Start     string `mapstructure:"start"`
+    End       string `mapstructure:"end"`
+    Rate      int    `mapstructure:"rate"`
+    Bandwidth string `mapstructure:"bandwidth"`
+}
+
+// 解析 "HH:MM"，返回距当天零点的分钟数
+func parseClock(value string) (int, error) {
+    t, err := time.Parse("15:04", value)
+    if err != nil {
+        return 0, fmt.Errorf("无效的时间 %q，应为 HH:MM 格式", value)
+    }
+    return t.Hour()*60 + t.Minute(), nil
+}
+
+// 校验速率时间段配置
+func validateRateSchedule(windows []RateWindow) error {
+    for _, w := range windows {
+        if _, err := parseClock(w.Start); err != nil {
+            return err
+        }
+        if _, err := parseClock(w.End); err != nil {
+            return err
+        }
+    }
+    return nil
+}
+
+// 判断时间是否落在时间段内，包含开始不包含结束
+func (w RateWindow) contains(now time.Time) bool {
+    start, _ := parseClock(w.Start)
+    end, _ := parseClock(w.End)
+    minute := now.Hour()*60 + now.Minute()
+    if start <= end {
+        return minute >= start && minute < end
+    }
+    return minute >= start || minute < end
+}
+
+// 按当前时间选择扫描速率和带宽，没有匹配的时间段时使用全局配置
+func (s *Scanner) scheduledRate(now time.Time) (int, string) {
+    rate, bandwidth := s.cfg.Rate, s.cfg.Bandwidth
+    for _, w := range s.cfg.RateSchedule {
+        if !w.contains(now) {
+            continue
+        }
+        if w.Rate > 0 {
+            rate = w.Rate
+        }
+        if w.Bandwidth != "" {
+            bandwidth = w.Bandwidth
+        }
+        slog.Info("按时间段调整速率", "start", w.Start, "end", w.End, "rate", rate, "bandwidth", bandwidth)
+        break
+    }
+    return rate, bandwidth
+}
@@ -0,0 +1,99 @@
+package scan
+
+import (
+    "context"
+    "errors"
+    "fmt"
+    "net/http"
+    "syscall"
+)
+
+// 服务协议
+const (
+    schemeHTTP  = "http"
+    schemeHTTPS = "https"
+    schemeAuto  = "auto"
+)
+
+// 校验协议配置
+func validateScheme(scheme string) error {
+    switch scheme {
+    case schemeHTTP, schemeHTTPS, schemeAuto:
+        return nil
+    default:
+        return fmt.Errorf("未知的协议: %s（可选 http、https、auto）", scheme)
+    }
+}
+
+// 目标使用的协议，auto 模式下返回检测时记录的协议，未记录时按 http 处理
+func (s *Scanner) schemeFor(ip string, port int) string {
+    if s.cfg.Scheme != schemeAuto {
+        return s.cfg.Scheme
+    }
+    if scheme, ok := s.schemes.Load(hostPort(ip, port)); ok {
+        return scheme.(string)
+    }
+    return schemeHTTP
+}
+
+// 记录目标使用的协议，性能测试沿用检测时确认的协议
+func (s *Scanner) rememberScheme(ip string, port int, scheme string) {
+    if scheme != "" {
+        s.schemes.Store(hostPort(ip, port), scheme)
+    }
+}
+
+// BaseURLFunc 根据协议和目标返回服务根地址（不含末尾斜杠），用于把请求指向测试服务或代理
+type BaseURLFunc func(scheme, ip string, port int) string
+
+// 默认直接访问目标
+func defaultBaseURL(scheme, ip string, port int) string {
+    return fmt.Sprintf("%s://%s", scheme, hostPort(ip, port))
+}
+
+// 替换服务根地址的生成方式，需在开始扫描前调用，传入 nil 恢复默认
+func (s *Scanner) SetBaseURL(fn BaseURLFunc) {
+    s.baseURLFunc = fn
+}
+
+// 使用指定协议生成服务根地址
+func (s *Scanner) baseURLWith(scheme, ip string, port int) string {
+    if s.baseURLFunc != nil {
+        return s.baseURLFunc(scheme, ip, port)
+    }
+    return defaultBaseURL(scheme, ip, port)
+}
+
+// 目标的服务根地址，检测、性能测试和预热等请求都由此拼接
+func (s *Scanner) baseURL(ip string, port int) string {
+    return s.baseURLWith(s.schemeFor(ip, port), ip, port)
+}
+
+// 拼接服务接口地址
+func (s *Scanner) serviceURL(ip string, port int, path string) string {
+    return s.baseURL(ip, port) + path
+}
+
+// 请求模型列表，auto 模式下先尝试 https，握手失败再回退到 http，收到HTTP响应的协议会被记录
+func (s *Scanner) fetchModels(ctx context.Context, ip string, port int) ([]ModelInfo, *http.Response, error) {
+    if s.cfg.Scheme != schemeAuto {
+        return s.fetchModelsWith(ctx, s.cfg.Scheme, ip, port)
+    }
+    if scheme, ok := s.schemes.Load(hostPort(ip, port)); ok {
+        return s.fetchModelsWith(ctx, scheme.(string), ip, port)
+    }
+
+    models, resp, err := s.fetchModelsWith(ctx, schemeHTTPS, ip, port)
+    // 端口未开放时不必再尝试 http
+    if resp == nil && !errors.Is(err, syscall.ECONNREFUSED) && ctx.Err() == nil {
+        models, resp, err = s.fetchModelsWith(ctx, schemeHTTP, ip, port)
+        if resp != nil {
+            s.rememberScheme(ip, port, schemeHTTP)
+        }
+        return models, resp, err
+    }
+    if resp != nil {
+        s.rememberScheme(ip, port, schemeHTTPS)
+    }
+    return models, resp, err
+}
@@ -0,0 +1,95 @@
+package scan
+
+import (
+    "context"
+    "encoding/json"
+    "fmt"
+    "io"
+    "net/http"
+)
+
+// 检测到的服务类型
+const (
+    serviceOllama  = "ollama"
+    serviceVLLM    = "vllm"
+    serviceOpenAI  = "openai-compat" // LM Studio、llama.cpp 等 OpenAI 兼容服务
+    serviceUnknown = "unknown"       // 健康检查有响应但无法识别
+)
+
+// 请求 OpenAI 风格的 /v1/models，vLLM 的模型归属固定为 vllm，其余按 OpenAI 兼容服务处理
+func (s *Scanner) getOpenAIModels(ctx context.Context, ip string, port int) (string, []ModelInfo, error) {
+    if err := s.inflight.acquire(ctx); err != nil {
+        return "", nil, err
+    }
+    defer s.inflight.release()
+
+    req, err := http.NewRequestWithContext(ctx, "GET", s.serviceURL(ip, port, "/v1/models"), nil)
+    if err != nil {
+        return "", nil, err
+    }
+    s.authorize(req, ip, port)
+    resp, err := s.httpClient.Do(req)
+    if err != nil {
+        return "", nil, err
+    }
+    defer resp.Body.Close()
+    if resp.StatusCode != http.StatusOK {
+        return "", nil, &statusError{code: resp.StatusCode}
+    }
+
+    var data struct {
+        Data []struct {
+            ID      string `json:"id"`
+            OwnedBy string `json:"owned_by"`
+        } `json:"data"`
+    }
+    if err := json.NewDecoder(resp.Body).Decode(&data); err != nil {
+        return "", nil, fmt.Errorf("%w: %v", errDecode, err)
+    }
+    service := serviceOpenAI
+    var models []ModelInfo
+    for _, m := range data.Data {
+        if m.ID == "" {
+            continue
+        }
+        if m.OwnedBy == serviceVLLM {
+            service = serviceVLLM
+        }
+        models = append(models, ModelInfo{Name: m.ID})
+    }
+    return service, models, nil
+}
+
+// 请求 /health，vLLM、llama.cpp 等服务在此返回200
+func (s *Scanner) checkHealth(ctx context.Context, ip string, port int) bool {
+    if err := s.inflight.acquire(ctx); err != nil {
+        return false
+    }
+    defer s.inflight.release()
+
+    req, err := http.NewRequestWithContext(ctx, "GET", s.serviceURL(ip, port, "/health"), nil)
+    if err != nil {
+        return false
+    }
+    s.authorize(req, ip, port)
+    resp, err := s.httpClient.Do(req)
+    if err != nil {
+        return false
+    }
+    io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+    resp.Body.Close()
+    return resp.StatusCode == http.StatusOK
+}
+
+// 模型列表接口不可用时识别同一端口上的其他大模型服务
+// 有模型时返回服务类型和模型，只有健康检查响应时返回 unknown，都没有响应时返回空类型
+func (s *Scanner) probeOtherServer(ctx context.Context, ip string, port int) (string, []ModelInfo) {
+    service, models, err := s.getOpenAIModels(ctx, ip, port)
+    if err == nil && len(models) > 0 {
+        return service, models
+    }
+    if ctx.Err() == nil && s.checkHealth(ctx, ip, port) {
+        return serviceUnknown, nil
+    }
+    return "", nil
+}
@@ -0,0 +1,198 @@
+package scan
+
+import (
+    "bytes"
+    "encoding/json"
+    "log/slog"
+    "net/http"
+    "os"
+    "sync"
+    "sync/atomic"
+    "time"
+)
+
+// 结果输出目标，发送失败只在内部计数，不影响其他输出
+type resultSink interface {
+    publish(stage, host string, result interface{})
+    close()
+}
+
+// 输出目标配置
+type SinkConfig struct {
+    Type    string        `mapstructure:"type"` // kafka、webhook、jsonl
+    URL     string        `mapstructure:"url"`
+    Path    string        `mapstructure:"path"`
+    Brokers []string      `mapstructure:"brokers"`
+    Topic   string        `mapstructure:"topic"`
+    Timeout time.Duration `mapstructure:"timeout"`
+}
+
+// 组合输出，每条结果分发到所有输出目标
+type multiSink []resultSink
+
+func (m multiSink) publish(stage, host string, result interface{}) {
+    for _, sink := range m {
+        sink.publish(stage, host, result)
+    }
+}
+
+func (m multiSink) close() {
+    for _, sink := range m {
+        sink.close()
+    }
+}
+
+// 按配置创建所有输出目标，kafkaBrokers/kafkaTopic 仍作为一个 Kafka 输出生效
+func (s *Scanner) newSinks() multiSink {
+    var sinks multiSink
+    if k := newKafkaSink(s.cfg.KafkaBrokers, s.cfg.KafkaTopic); k != nil {
+        sinks = append(sinks, k)
+    }
+    for _, c := range s.cfg.Sinks {
+        switch c.Type {
+        case "kafka":
+            if k := newKafkaSink(c.Brokers, c.Topic); k != nil {
+                sinks = append(sinks, k)
+            }
+        case "webhook":
+            sinks = append(sinks, newWebhookSink(c.URL, c.Timeout))
+        case "jsonl":
+            sink, err := newJSONLSink(c.Path, s.fileMode)
+            if err != nil {
+                slog.Warn("创建JSONL输出失败", "error", err)
+                continue
+            }
+            sinks = append(sinks, sink)
+        default:
+            slog.Warn("未知的输出类型", "type", c.Type)
+        }
+    }
+    return sinks
+}
+
+// 输出消息格式
+type sinkMessage struct {
+    Stage  string      `json:"stage"`
+    Host   string      `json:"host"`
+    Result interface{} `json:"result"`
+}
+
+// 尽力发送的 POST 队列，后台协程逐条发送，队列已满时直接丢弃，调用方不会被慢速或不可用的接收方拖慢
+// Webhook 输出和关注模型通知共用
+type webhookPoster struct {
+    url     string
+    client  *http.Client
+    queue   chan []byte
+    done    chan struct{}
+    dropped int64
+    failed  int64
+}
+
+func newWebhookPoster(url string, timeout time.Duration, size int) *webhookPoster {
+    if timeout <= 0 {
+        timeout = 5 * time.Second
+    }
+    p := &webhookPoster{
+        url:    url,
+        client: &http.Client{Timeout: timeout},
+        queue:  make(chan []byte, size),
+        done:   make(chan struct{}),
+    }
+    go p.loop()
+    return p
+}
+
+func (p *webhookPoster) loop() {
+    defer close(p.done)
+    for body := range p.queue {
+        resp, err := p.client.Post(p.url, "application/json", bytes.NewReader(body))
+        if err != nil {
+            atomic.AddInt64(&p.failed, 1)
+            continue
+        }
+        resp.Body.Close()
+        if resp.StatusCode >= 300 {
+            atomic.AddInt64(&p.failed, 1)
+        }
+    }
+}
+
+// 加入发送队列，队列已满时丢弃
+func (p *webhookPoster) post(body []byte) {
+    select {
+    case p.queue <- body:
+    default:
+        atomic.AddInt64(&p.dropped, 1)
+    }
+}
+
+// 发送队列中剩余的消息后退出，返回发送失败和被丢弃的数量
+func (p *webhookPoster) close() (failed, dropped int64) {
+    close(p.queue)
+    <-p.done
+    return atomic.LoadInt64(&p.failed), atomic.LoadInt64(&p.dropped)
+}
+
+// Webhook 输出，后台协程逐条POST结果，避免阻塞结果写入
+type webhookSink struct {
+    poster *webhookPoster
+}
+
+func newWebhookSink(url string, timeout time.Duration) *webhookSink {
+    return &webhookSink{poster: newWebhookPoster(url, timeout, 1000)}
+}
+
+func (w *webhookSink) publish(stage, host string, result interface{}) {
+    body, err := json.Marshal(sinkMessage{Stage: stage, Host: host, Result: result})
+    if err != nil {
+        return
+    }
+    w.poster.post(body)
+}
+
+// 发送剩余结果后退出
+func (w *webhookSink) close() {
+    failed, dropped := w.poster.close()
+    if failed > 0 {
+        slog.Warn("结果发送到Webhook失败", "count", failed)
+    }
+    if dropped > 0 {
+        slog.Warn("Webhook发送队列已满，丢弃结果", "count", dropped)
+    }
+}
+
+// JSONL 输出，每条结果追加为一行JSON
+type jsonlSink struct {
+    mu     sync.Mutex
+    file   *os.File
+    failed int64
+}
+
+func newJSONLSink(path string, mode os.FileMode) (*jsonlSink, error) {
+    file, err := openFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, mode)
+    if err != nil {
+        return nil, err
+    }
+    return &jsonlSink{file: file}, nil
+}
+
+func (j *jsonlSink) publish(stage, host string, result interface{}) {
+    line, err := json.Marshal(sinkMessage{Stage: stage, Host: host, Result: result})
+    if err != nil {
+        return
+    }
+    j.mu.Lock()
+    defer j.mu.Unlock()
+    if _, err := j.file.Write(append(line, '\n')); err != nil {
+        j.failed++
+    }
+}
+
+func (j *jsonlSink) close() {
+    if err := j.file.Close(); err != nil {
+        slog.Warn("关闭JSONL文件失败", "error", err)
+    }
+    if j.failed > 0 {
+        slog.Warn("结果写入JSONL失败", "count", j.failed)
+    }
+}
@@ -0,0 +1,47 @@
+package scan
+
+import (
+    "bytes"
+    "encoding/json"
+)
+
+// OpenAI 流式响应中标记结束的数据
+const sseDone = "[DONE]"
+
+// OpenAI 兼容接口的流式响应块
+type openAIChunk struct {
+    Choices []struct {
+        Delta struct {
+            Content string `json:"content"`
+        } `json:"delta"`
+    } `json:"choices"`
+    // 请求 stream_options.include_usage 时最后一块附带Token统计
+    Usage *struct {
+        PromptTokens     int `json:"prompt_tokens"`
+        CompletionTokens int `json:"completion_tokens"`
+    } `json:"usage"`
+}
+
+// 生成的文本
+func (c openAIChunk) text() string {
+    if len(c.Choices) == 0 {
+        return ""
+    }
+    return c.Choices[0].Delta.Content
+}
+
+// 解析一行 SSE 数据，空行、注释和事件名等非数据行返回false，done 表示收到结束标记
+func parseSSELine(line []byte) (chunk openAIChunk, ok, done bool) {
+    data, found := bytes.CutPrefix(line, []byte("data:"))
+    if !found {
+        return chunk, false, false
+    }
+    data = bytes.TrimSpace(data)
+    if string(data) == sseDone {
+        return chunk, false, true
+    }
+    if err := json.Unmarshal(data, &chunk); err != nil {
+        return chunk, false, false
+    }
+    return chunk, true, false
+}
@@ -0,0 +1,119 @@
+package scan
+
+import (
+    "database/sql"
+    "fmt"
+    "log/slog"
+    "os"
+    "sync/atomic"
+    "time"
+
+    _ "modernc.org/sqlite"
+)
+
+// 结果数据库表结构
+// endpoints 每个(IP, 端口, 模型)一行，记录首次和最后一次发现时间，用于对比各次检测之间消失的服务
+// benchmarks 每次测试追加一行，保留历史测试记录
+const storeSchema = `
+CREATE TABLE IF NOT EXISTS endpoints (
+    ip         TEXT    NOT NULL,
+    port       INTEGER NOT NULL,
+    model      TEXT    NOT NULL,
+    status     TEXT    NOT NULL,
+    first_seen TEXT    NOT NULL,
+    last_seen  TEXT    NOT NULL,
+    PRIMARY KEY (ip, port, model)
+);
+CREATE TABLE IF NOT EXISTS benchmarks (
+    id             INTEGER PRIMARY KEY AUTOINCREMENT,
+    ip             TEXT    NOT NULL,
+    port           INTEGER NOT NULL,
+    model          TEXT    NOT NULL,
+    status         TEXT    NOT NULL,
+    first_token_ms INTEGER NOT NULL,
+    tokens_per_sec REAL    NOT NULL,
+    prompt_length  TEXT    NOT NULL,
+    probe_label    TEXT    NOT NULL,
+    created_at     TEXT    NOT NULL
+);
+CREATE INDEX IF NOT EXISTS benchmarks_endpoint ON benchmarks (ip, port, model);
+`
+
+// 时间使用 SQLite 原生格式（UTC），可以直接与 datetime('now', '-1 day') 比较
+const storeTimeLayout = "2006-01-02 15:04:05"
+
+// SQLite 结果库，未配置 database 时为nil，所有方法都可以在nil上调用
+type resultStore struct {
+    db     *sql.DB
+    failed atomic.Int64
+}
+
+// 打开结果数据库并建表
+func (s *Scanner) openStore() (*resultStore, error) {
+    if s.cfg.Database == "" {
+        return nil, nil
+    }
+    db, err := sql.Open("sqlite", s.cfg.Database)
+    if err != nil {
+        return nil, fmt.Errorf("打开数据库失败: %w", err)
+    }
+    // SQLite 同一时间只允许一个写入者，单连接避免 database is locked
+    db.SetMaxOpenConns(1)
+    if _, err := db.Exec(storeSchema); err != nil {
+        db.Close()
+        return nil, fmt.Errorf("初始化数据库失败: %w", err)
+    }
+    // 与其他输出文件使用相同的权限
+    os.Chmod(s.cfg.Database, s.fileMode)
+    return &resultStore{db: db}, nil
+}
+
+// 更新检测结果，已存在的组合只刷新状态和最后发现时间
+func (st *resultStore) recordDetect(r DetectResult) {
+    if st == nil {
+        return
+    }
+    now := time.Now().UTC().Format(storeTimeLayout)
+    _, err := st.db.Exec(`
+        INSERT INTO endpoints (ip, port, model, status, first_seen, last_seen)
+        VALUES (?, ?, ?, ?, ?, ?)
+        ON CONFLICT (ip, port, model) DO UPDATE SET
+            status = excluded.status,
+            last_seen = excluded.last_seen`,
+        r.IP, r.Port, r.Model, r.Status, now, now)
+    st.check(err)
+}
+
+// 追加一条测试记录
+func (st *resultStore) recordBench(r BenchResult) {
+    if st == nil {
+        return
+    }
+    _, err := st.db.Exec(`
+        INSERT INTO benchmarks (ip, port, model, status, first_token_ms, tokens_per_sec, prompt_length, probe_label, created_at)
+        VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+        r.IP, r.Port, r.Model, r.Status, r.FirstTokenMs, r.TokensPerSec, r.PromptLength, r.ProbeLabel,
+        time.Now().UTC().Format(storeTimeLayout))
+    st.check(err)
+}
+
+// 写入失败只提示第一次，其余计数后在关闭时汇总
+func (st *resultStore) check(err error) {
+    if err == nil {
+        return
+    }
+    if st.failed.Add(1) == 1 {
+        slog.Warn("写入数据库失败", "error", err)
+    }
+}
+
+// 关闭数据库
+func (st *resultStore) close() {
+    if st == nil {
+        return
+    }
+    if failed := st.failed.Load(); failed > 0 {
+        slog.Warn("结果写入数据库失败", "count", failed)
+    }
+    st.db.Close()
+}
@@ -0,0 +1,47 @@
+package scan
+
+import (
+    "context"
+    "log/slog"
+    "sync"
+)
+
+// 对同一主机的同一模型同时发起多路生成，统计聚合吞吐和相对单流的衰减
+// 各路请求同样受 maxPerHost 约束，上限小于路数时多路测试会被串行化
+func (s *Scanner) benchmarkStreams(ctx context.Context, result *BenchResult, prompt string) {
+    streams := s.cfg.MultiStreams
+    tps := make([]float64, streams)
+    var wg sync.WaitGroup
+    for i := 0; i < streams; i++ {
+        wg.Add(1)
+        go func(i int) {
+            defer wg.Done()
+            stream := s.benchmarkModel(ctx, result.IP, result.Port, result.Model, prompt)
+            if stream.Status == "成功" {
+                tps[i] = stream.TokensPerSec
+            }
+        }(i)
+    }
+    wg.Wait()
+
+    var sum float64
+    var succeeded int
+    for _, v := range tps {
+        if v > 0 {
+            sum += v
+            succeeded++
+        }
+    }
+    result.Streams = streams
+    result.AggregateTps = sum
+    if succeeded > 0 && result.TokensPerSec > 0 {
+        result.StreamDegradation = 1 - sum/float64(succeeded)/result.TokensPerSec
+    }
+    slog.Info("多路测试",
+        "ip", result.IP,
+        "model", result.Model,
+        "streams", streams,
+        "succeeded", succeeded,
+        "aggregate_tps", sum,
+        "degradation", result.StreamDegradation)
+}
@@ -0,0 +1,105 @@
+package scan
+
+import (
+    "encoding/json"
+    "log/slog"
+    "path/filepath"
+    "sync"
+)
+
+// 阶段结束时的汇总，由工作协程并发累计
+type stageSummary struct {
+    Stage    string         `json:"stage"`
+    Probed   int            `json:"probed"`
+    Found    int            `json:"found"`
+    Models   int            `json:"unique_models"`
+    Fastest  *summaryResult `json:"fastest,omitempty"`
+    Slowest  *summaryResult `json:"slowest,omitempty"`
+    Failures map[string]int `json:"failures"`
+    models   map[string]bool
+    mu       sync.Mutex
+}
+
+// 汇总中的最快和最慢结果
+type summaryResult struct {
+    IP           string  `json:"ip"`
+    Port         int     `json:"port"`
+    Model        string  `json:"model"`
+    TokensPerSec float64 `json:"tokens_per_sec"`
+}
+
+func newStageSummary(stage string) *stageSummary {
+    return &stageSummary{Stage: stage, models: make(map[string]bool)}
+}
+
+// 检测阶段完成一个目标的探测，models 为发现的模型，为空表示未发现服务
+func (m *stageSummary) probe(models []string) {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+    m.Probed++
+    if len(models) == 0 {
+        return
+    }
+    m.Found++
+    for _, name := range models {
+        m.models[name] = true
+    }
+}
+
+// 性能测试阶段完成一次测试，只有成功的结果参与速度排名
+func (m *stageSummary) bench(result BenchResult) {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+    m.Probed++
+    if result.Status != "成功" {
+        return
+    }
+    m.Found++
+    m.models[result.Model] = true
+    if result.TokensPerSec <= 0 {
+        // 快速模式不测生成速度
+        return
+    }
+    r := &summaryResult{IP: result.IP, Port: result.Port, Model: result.Model, TokensPerSec: result.TokensPerSec}
+    if m.Fastest == nil || r.TokensPerSec > m.Fastest.TokensPerSec {
+        m.Fastest = r
+    }
+    if m.Slowest == nil || r.TokensPerSec < m.Slowest.TokensPerSec {
+        m.Slowest = r
+    }
+}
+
+// 打印阶段汇总，配置 summaryDir 时同时写入 <阶段>.summary.json，失败原因取自 failures
+func (s *Scanner) reportSummary(m *stageSummary, failures *failureStats) {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+    m.Models = len(m.models)
+    m.Failures = make(map[string]int)
+    for _, reason := range failures.sorted() {
+        m.Failures[reason] = failures.reasons[reason]
+    }
+
+    attrs := []any{"stage", m.Stage, "probed", m.Probed, "found", m.Found, "unique_models", m.Models}
+    if m.Fastest != nil {
+        attrs = append(attrs,
+            "fastest", m.Fastest.Model+"@"+hostPort(m.Fastest.IP, m.Fastest.Port),
+            "fastest_tps", m.Fastest.TokensPerSec,
+            "slowest", m.Slowest.Model+"@"+hostPort(m.Slowest.IP, m.Slowest.Port),
+            "slowest_tps", m.Slowest.TokensPerSec)
+    }
+    slog.Info("阶段汇总", attrs...)
+    // 失败原因明细已由 reportFailures 逐条打印
+
+    if s.cfg.SummaryDir == "" {
+        return
+    }
+    data, err := json.MarshalIndent(m, "", "  ")
+    if err != nil {
+        slog.Warn("生成阶段汇总失败", "error", err)
+        return
+    }
+    path := filepath.Join(s.cfg.SummaryDir, m.Stage+".summary.json")
+    if err := writeFileAtomic(path, data, s.fileMode); err != nil {
+        slog.Warn("写入阶段汇总失败", "error", err)
+    }
+}
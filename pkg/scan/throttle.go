@@ -0,0 +1,131 @@
+package scan
+
+import (
+    "context"
+    "log/slog"
+    "sync"
+)
+
+// 单台主机的并发状态
+type hostState struct {
+    cond     *sync.Cond
+    limit    int       // 当前允许的并发数
+    inflight int       // 在途请求数
+    samples  []float64 // 当前并发下采集的单请求 tokens/s
+    baseline float64   // 低并发时的单请求 tokens/s
+    settled  bool      // 已找到合适的并发数，不再上调
+}
+
+// 按主机限制性能测试并发，开启自适应时根据单请求吞吐变化调整并发
+type hostThrottle struct {
+    max       int
+    adaptive  bool
+    window    int
+    tolerance float64
+    mu        sync.Mutex
+    hosts     map[string]*hostState
+}
+
+// 创建主机并发限制器，max 小于等于0时不启用
+func newHostThrottle(max int, adaptive bool, window int, tolerance float64) *hostThrottle {
+    if max <= 0 {
+        return nil
+    }
+    if window < 1 {
+        window = 1
+    }
+    return &hostThrottle{
+        max:       max,
+        adaptive:  adaptive,
+        window:    window,
+        tolerance: tolerance,
+        hosts:     make(map[string]*hostState),
+    }
+}
+
+// 获取主机状态，自适应模式从单并发开始逐步上调
+func (t *hostThrottle) state(host string) *hostState {
+    st, ok := t.hosts[host]
+    if !ok {
+        st = &hostState{cond: sync.NewCond(&t.mu), limit: t.max}
+        if t.adaptive {
+            st.limit = 1
+        }
+        t.hosts[host] = st
+    }
+    return st
+}
+
+// 等待主机空闲名额，ctx 取消时不再等待，返回其错误
+func (t *hostThrottle) acquire(ctx context.Context, host string) error {
+    if t == nil {
+        return nil
+    }
+    t.mu.Lock()
+    defer t.mu.Unlock()
+
+    st := t.state(host)
+    if st.inflight >= st.limit {
+        // 取消时唤醒等待者，让它检查 ctx
+        stop := context.AfterFunc(ctx, func() {
+            t.mu.Lock()
+            defer t.mu.Unlock()
+            st.cond.Broadcast()
+        })
+        defer stop()
+    }
+    for st.inflight >= st.limit {
+        if err := ctx.Err(); err != nil {
+            return err
+        }
+        st.cond.Wait()
+    }
+    st.inflight++
+    return nil
+}
+
+// 释放名额并记录本次请求的 tokens/s，失败请求传0不计入采样
+func (t *hostThrottle) release(host string, tps float64) {
+    if t == nil {
+        return
+    }
+    t.mu.Lock()
+    defer t.mu.Unlock()
+
+    st := t.state(host)
+    st.inflight--
+    if t.adaptive && tps > 0 {
+        st.samples = append(st.samples, tps)
+        if len(st.samples) >= t.window {
+            t.adjust(host, st)
+        }
+    }
+    st.cond.Broadcast()
+}
+
+// 根据采样窗口调整并发：单请求吞吐未明显下降则上调，否则回退并固定
+func (t *hostThrottle) adjust(host string, st *hostState) {
+    var sum float64
+    for _, v := range st.samples {
+        sum += v
+    }
+    avg := sum / float64(len(st.samples))
+    st.samples = st.samples[:0]
+
+    if st.baseline == 0 || avg >= st.baseline*(1-t.tolerance) {
+        if avg > st.baseline {
+            st.baseline = avg
+        }
+        if !st.settled && st.limit < t.max {
+            st.limit++
+        }
+        return
+    }
+
+    // 单请求吞吐下降说明GPU已饱和
+    if st.limit > 1 {
+        st.limit--
+        slog.Info("主机出现资源争用，调整并发数", "host", host, "limit", st.limit)
+    }
+    st.settled = true
+}
@@ -0,0 +1,45 @@
+package scan
+
+import (
+    "context"
+    "fmt"
+
+    "go.opentelemetry.io/otel/attribute"
+    "go.opentelemetry.io/otel/codes"
+    "go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+    "go.opentelemetry.io/otel/sdk/resource"
+    sdktrace "go.opentelemetry.io/otel/sdk/trace"
+    "go.opentelemetry.io/otel/trace"
+    "go.opentelemetry.io/otel/trace/noop"
+)
+
+// 初始化链路追踪，未配置 otelEndpoint 时返回空实现
+func newTracer(endpoint string) (trace.Tracer, *sdktrace.TracerProvider, error) {
+    if endpoint == "" {
+        return noop.NewTracerProvider().Tracer("scan"), nil, nil
+    }
+
+    exporter, err := otlptracehttp.New(context.Background(),
+        otlptracehttp.WithEndpointURL(endpoint),
+    )
+    if err != nil {
+        return nil, nil, fmt.Errorf("创建OTLP导出器失败: %w", err)
+    }
+
+    provider := sdktrace.NewTracerProvider(
+        sdktrace.WithBatcher(exporter),
+        sdktrace.WithResource(resource.NewSchemaless(
+            attribute.String("service.name", "scan"),
+        )),
+    )
+    return provider.Tracer("scan"), provider, nil
+}
+
+// 结束span，出错时记录错误状态
+func endSpan(span trace.Span, err error) {
+    if err != nil {
+        span.RecordError(err)
+        span.SetStatus(codes.Error, err.Error())
+    }
+    span.End()
+}
@@ -0,0 +1,286 @@
+package scan
+
+import (
+    "bytes"
+    "fmt"
+    "io"
+    "log/slog"
+    "os"
+    "sort"
+    "strings"
+    "sync"
+    "time"
+
+    tea "github.com/charmbracelet/bubbletea"
+)
+
+// 终端面板刷新间隔和滚动窗口
+const (
+    dashboardRefresh = 500 * time.Millisecond
+    dashboardWindow  = 10 * time.Second
+    dashboardLines   = 8
+)
+
+// 吞吐采样点
+type rateSample struct {
+    at   time.Time
+    done int
+}
+
+// 终端面板，阶段运行期间由 bubbletea 定时重绘
+// 进度取自健康状态，计数取自阶段清单，发现的服务和测试结果与结果库在同一处登记，
+// 日志和扫描程序的输出转入面板的事件区，不直接写终端覆盖画面
+type dashboard struct {
+    mu          sync.Mutex
+    health      *healthState
+    manifests   []*stageManifest
+    discoveries []string
+    events      []string
+    partial     []byte // 事件输出中尚未换行的部分
+    samples     []rateSample
+    started     time.Time
+    lastStage   string // 最近一次非空闲阶段，阶段结束后仍展示
+    done        int
+    total       int
+    rate        float64
+    active      bool
+
+    program *tea.Program
+    prevLog io.Writer
+    runDone chan struct{}
+}
+
+// 开启终端面板模式
+func (s *Scanner) EnableDashboard() {
+    s.dash = &dashboard{health: s.health}
+}
+
+// 登记需要展示计数的阶段清单
+func (d *dashboard) watch(m *stageManifest) {
+    if d == nil {
+        return
+    }
+    d.mu.Lock()
+    defer d.mu.Unlock()
+    d.manifests = append(d.manifests, m)
+}
+
+// 阶段开始时启动面板，日志转入事件区
+func (d *dashboard) start() {
+    if d == nil {
+        return
+    }
+    d.mu.Lock()
+    d.manifests = nil
+    d.discoveries = nil
+    d.events = nil
+    d.partial = nil
+    d.samples = nil
+    d.started = time.Now()
+    d.lastStage = "idle"
+    d.active = true
+    d.mu.Unlock()
+    d.refresh()
+
+    // 不读取输入，终端保持正常模式，Ctrl+C 仍作为信号交给 RunStage 处理
+    d.program = tea.NewProgram(dashboardModel{d: d},
+        tea.WithOutput(os.Stdout),
+        tea.WithInput(nil),
+        tea.WithoutSignalHandler())
+    d.prevLog = stderrLog.redirect(d)
+    d.runDone = make(chan struct{})
+    go func() {
+        defer close(d.runDone)
+        if _, err := d.program.Run(); err != nil {
+            stderrLog.redirect(d.prevLog)
+            slog.Warn("终端面板退出", "error", err)
+        }
+    }()
+}
+
+// 阶段结束时停止面板，保留最后一帧画面并恢复日志输出
+func (d *dashboard) stop() {
+    if d == nil || d.program == nil {
+        return
+    }
+    d.refresh()
+    d.program.Quit()
+    <-d.runDone
+    d.program = nil
+    stderrLog.redirect(d.prevLog)
+
+    d.mu.Lock()
+    d.active = false
+    d.mu.Unlock()
+}
+
+// 面板运行期间外部程序的输出写入事件区，否则写入 w
+func (d *dashboard) output(w io.Writer) io.Writer {
+    if d == nil {
+        return w
+    }
+    d.mu.Lock()
+    defer d.mu.Unlock()
+    if !d.active {
+        return w
+    }
+    return d
+}
+
+// 按行收集日志和外部程序的输出
+func (d *dashboard) Write(p []byte) (int, error) {
+    d.mu.Lock()
+    defer d.mu.Unlock()
+    d.partial = append(d.partial, p...)
+    for {
+        i := bytes.IndexByte(d.partial, '\n')
+        if i < 0 {
+            break
+        }
+        if line := strings.TrimSpace(string(d.partial[:i])); line != "" {
+            d.events = appendRecent(d.events, line)
+        }
+        d.partial = d.partial[i+1:]
+    }
+    return len(p), nil
+}
+
+// 登记发现的服务或测试成功的结果
+func (d *dashboard) discover(line string) {
+    if d == nil {
+        return
+    }
+    d.mu.Lock()
+    defer d.mu.Unlock()
+    d.discoveries = appendRecent(d.discoveries, line)
+}
+
+// 检测到可用模型
+func (d *dashboard) detected(result DetectResult) {
+    if d == nil || result.Status != "成功" {
+        return
+    }
+    d.discover(fmt.Sprintf("%s  %s", hostPort(result.IP, result.Port), result.Model))
+}
+
+// 性能测试成功
+func (d *dashboard) benchmarked(result BenchResult) {
+    if d == nil || result.Status != "成功" {
+        return
+    }
+    d.discover(fmt.Sprintf("%s  %s  %.1f tokens/s", hostPort(result.IP, result.Port), result.Model, result.TokensPerSec))
+}
+
+// 追加一行并只保留最近的若干行
+func appendRecent(lines []string, line string) []string {
+    lines = append(lines, line)
+    if len(lines) > dashboardLines {
+        lines = lines[len(lines)-dashboardLines:]
+    }
+    return lines
+}
+
+// 读取当前阶段和进度，更新滚动窗口内的处理速率
+func (d *dashboard) refresh() {
+    d.health.mu.Lock()
+    stage, done, total := d.health.stage, d.health.done, d.health.total
+    d.health.mu.Unlock()
+
+    d.mu.Lock()
+    defer d.mu.Unlock()
+    if stage != "idle" {
+        d.lastStage = stage
+    }
+    d.done, d.total = done, total
+
+    now := time.Now()
+    d.samples = append(d.samples, rateSample{at: now, done: done})
+    for len(d.samples) > 1 && now.Sub(d.samples[0].at) > dashboardWindow {
+        d.samples = d.samples[1:]
+    }
+    first := d.samples[0]
+    if elapsed := now.Sub(first.at).Seconds(); elapsed > 0 {
+        d.rate = float64(done-first.done) / elapsed
+    } else {
+        d.rate = 0
+    }
+}
+
+// 绘制面板
+func (d *dashboard) view() string {
+    d.mu.Lock()
+    defer d.mu.Unlock()
+
+    var b strings.Builder
+    fmt.Fprintf(&b, "🦙 Ollama 扫描面板    阶段: %s    已运行: %s\n",
+        d.lastStage, time.Since(d.started).Truncate(time.Second))
+    b.WriteString(strings.Repeat("─", 60) + "\n")
+
+    progress := fmt.Sprintf("%d", d.done)
+    if d.total > 0 {
+        progress = fmt.Sprintf("%d / %d (%.1f%%)", d.done, d.total, float64(d.done)*100/float64(d.total))
+    }
+    fmt.Fprintf(&b, "进度: %s    速率: %.1f/s (最近%v)\n", progress, d.rate, dashboardWindow)
+
+    for _, m := range d.manifests {
+        m.mu.Lock()
+        names := make([]string, 0, len(m.Counts))
+        for name := range m.Counts {
+            names = append(names, name)
+        }
+        sort.Strings(names)
+        parts := make([]string, 0, len(names))
+        for _, name := range names {
+            parts = append(parts, fmt.Sprintf("%s=%d", name, m.Counts[name]))
+        }
+        errors := m.Counts["failed"] + m.Counts["errors"]
+        attempts := m.Counts["success"] + m.Counts["failed"]
+        if m.Counts["targets"] > 0 {
+            attempts = m.Counts["targets"]
+        }
+        m.mu.Unlock()
+
+        fmt.Fprintf(&b, "\n[%s] %s\n", m.Stage, strings.Join(parts, "  "))
+        if attempts > 0 {
+            fmt.Fprintf(&b, "[%s] 错误率: %.1f%%\n", m.Stage, float64(errors)*100/float64(attempts))
+        }
+    }
+
+    b.WriteString("\n最近发现:\n")
+    for _, line := range d.discoveries {
+        b.WriteString("  " + line + "\n")
+    }
+    b.WriteString("\n最近事件:\n")
+    for _, line := range d.events {
+        b.WriteString("  " + line + "\n")
+    }
+    return b.String()
+}
+
+// 定时重绘的消息
+type dashboardTick struct{}
+
+func dashboardTickCmd() tea.Cmd {
+    return tea.Tick(dashboardRefresh, func(time.Time) tea.Msg { return dashboardTick{} })
+}
+
+// bubbletea 模型，状态都在 dashboard 中，模型只负责定时刷新和绘制
+type dashboardModel struct {
+    d *dashboard
+}
+
+func (m dashboardModel) Init() tea.Cmd {
+    return dashboardTickCmd()
+}
+
+func (m dashboardModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+    if _, ok := msg.(dashboardTick); ok {
+        m.d.refresh()
+        return m, dashboardTickCmd()
+    }
+    return m, nil
+}
+
+func (m dashboardModel) View() string {
+    return m.d.view()
+}
@@ -0,0 +1,70 @@
+package scan
+
+import (
+    "fmt"
+    "net/http"
+
+    "golang.org/x/net/http/httpguts"
+)
+
+// 版本号，发布构建时通过 -ldflags 注入，未注入时为 dev
+var Version = "dev"
+
+// 未配置 userAgent 时使用的默认值，便于被扫描方识别来源
+func defaultUserAgent() string {
+    return "scan/" + Version
+}
+
+// 为每个发出的请求附加 User-Agent 和配置的自定义请求头
+// 请求自身已设置的头（如按目标附加的认证头、Content-Type）优先，不会被覆盖
+type headerTransport struct {
+    base      http.RoundTripper
+    userAgent string
+    headers   http.Header
+}
+
+// 包装传输层，附加配置的请求头
+func newHeaderTransport(base http.RoundTripper, userAgent string, headers map[string]string) *headerTransport {
+    if userAgent == "" {
+        userAgent = defaultUserAgent()
+    }
+    t := &headerTransport{base: base, userAgent: userAgent, headers: make(http.Header)}
+    for name, value := range headers {
+        t.headers.Set(name, value)
+    }
+    return t
+}
+
+func (t *headerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+    // RoundTrip 不能修改调用方的请求，复制后再附加
+    req = req.Clone(req.Context())
+    if req.Header.Get("User-Agent") == "" {
+        req.Header.Set("User-Agent", t.userAgent)
+    }
+    for name, values := range t.headers {
+        if _, ok := req.Header[name]; !ok {
+            req.Header[name] = values
+        }
+    }
+    return t.base.RoundTrip(req)
+}
+
+// 转发给底层传输层，http.Client.CloseIdleConnections 依赖此方法
+func (t *headerTransport) CloseIdleConnections() {
+    if closer, ok := t.base.(interface{ CloseIdleConnections() }); ok {
+        closer.CloseIdleConnections()
+    }
+}
+
+// 校验自定义请求头的名称和取值
+func validateHeaders(headers map[string]string) error {
+    for name, value := range headers {
+        if !httpguts.ValidHeaderFieldName(name) {
+            return fmt.Errorf("无效的请求头名称: %q", name)
+        }
+        if !httpguts.ValidHeaderFieldValue(value) {
+            return fmt.Errorf("请求头 %s 的取值无效", name)
+        }
+    }
+    return nil
+}
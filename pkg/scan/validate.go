@@ -0,0 +1,164 @@
+package scan
+
+import (
+    "fmt"
+    "strconv"
+)
+
+// 校验配置，取值越界或缺少必填项时返回说明具体字段的错误
+func (c *Config) Validate() error {
+    if c.Port < 1 || c.Port > 65535 {
+        return fmt.Errorf("port 应在 1-65535 之间，当前为 %d", c.Port)
+    }
+    if _, err := c.portList(); err != nil {
+        return fmt.Errorf("解析端口列表失败: %w", err)
+    }
+    if c.Rate <= 0 {
+        return fmt.Errorf("rate 应大于0，当前为 %d", c.Rate)
+    }
+    if c.MaxWorkers <= 0 {
+        return fmt.Errorf("maxWorkers 应大于0，当前为 %d", c.MaxWorkers)
+    }
+    if c.Timeout <= 0 {
+        return fmt.Errorf("timeout 应大于0，当前为 %v", c.Timeout)
+    }
+    if c.BenchTimeout <= 0 {
+        return fmt.Errorf("benchTimeout 应大于0，当前为 %v", c.BenchTimeout)
+    }
+    if c.BenchConnectTimeout <= 0 {
+        return fmt.Errorf("benchConnectTimeout 应大于0，当前为 %v", c.BenchConnectTimeout)
+    }
+    // 用作定时器间隔，为0时 time.NewTicker 会直接panic
+    if c.CheckpointInterval <= 0 {
+        return fmt.Errorf("checkpointInterval 应大于0，当前为 %v", c.CheckpointInterval)
+    }
+    if c.AutoscaleInterval <= 0 {
+        return fmt.Errorf("autoscaleInterval 应大于0，当前为 %v", c.AutoscaleInterval)
+    }
+    if c.BreakerCooldown < 0 {
+        return fmt.Errorf("breakerCooldown 不能为负数，当前为 %v", c.BreakerCooldown)
+    }
+    if c.DecodeRetryDelay < 0 {
+        return fmt.Errorf("decodeRetryDelay 不能为负数，当前为 %v", c.DecodeRetryDelay)
+    }
+    if c.RetryBackoff < 0 {
+        return fmt.Errorf("retryBackoff 不能为负数，当前为 %v", c.RetryBackoff)
+    }
+    if c.DrainTimeout < 0 {
+        return fmt.Errorf("drainTimeout 不能为负数，当前为 %v", c.DrainTimeout)
+    }
+    // 同时用作连接超时和读取期限，为0时横幅读取会立即超时
+    if c.BannerTimeout <= 0 {
+        return fmt.Errorf("bannerTimeout 应大于0，当前为 %v", c.BannerTimeout)
+    }
+    if c.ThrottleWindow < 1 {
+        return fmt.Errorf("throttleWindow 至少为1，当前为 %d", c.ThrottleWindow)
+    }
+    if c.Limit < 0 {
+        return fmt.Errorf("limit 不能为负数，当前为 %d", c.Limit)
+    }
+    if c.BenchRepeat < 1 {
+        return fmt.Errorf("benchRepeat 至少为1，当前为 %d", c.BenchRepeat)
+    }
+    if c.DetectRate < 0 {
+        return fmt.Errorf("detectRate 不能为负数，当前为 %v", c.DetectRate)
+    }
+    if c.EmbedRequests <= 0 {
+        return fmt.Errorf("embedRequests 应大于0，当前为 %d", c.EmbedRequests)
+    }
+
+    // 各阶段读写的文件不能为空
+    for name, value := range map[string]string{
+        "inputFile":        c.InputFile,
+        "outputFile":       c.OutputFile,
+        "scanOutputFile":   c.ScanOutputFile,
+        "ollamaOutputFile": c.OllamaOutputFile,
+    } {
+        if value == "" {
+            return fmt.Errorf("%s 不能为空", name)
+        }
+    }
+
+    if _, err := strconv.ParseUint(c.OutputFileMode, 8, 32); err != nil {
+        return fmt.Errorf("解析输出文件权限失败: %w", err)
+    }
+    if err := validateRateSchedule(c.RateSchedule); err != nil {
+        return fmt.Errorf("解析速率时间表失败: %w", err)
+    }
+    if err := validateScanner(c.Scanner); err != nil {
+        return err
+    }
+    if err := validateOutputFormat(c.OutputFormat); err != nil {
+        return err
+    }
+    if err := validateScheme(c.Scheme); err != nil {
+        return err
+    }
+    if err := validateBenchEndpoint(c.BenchEndpoint); err != nil {
+        return err
+    }
+    if err := validateLanguage(c.Language); err != nil {
+        return err
+    }
+    if err := validateRedact(c.RedactIP, c.RedactSalt); err != nil {
+        return err
+    }
+    if err := validateSampleStrategy(c.SampleStrategy); err != nil {
+        return err
+    }
+    if _, err := parseProxy(c.Proxy); err != nil {
+        return err
+    }
+    for i, p := range c.BenchPrompts {
+        if p.Model == "" || p.Prompt == "" {
+            return fmt.Errorf("benchPrompts 第%d条的 model 和 prompt 不能为空", i+1)
+        }
+    }
+    if _, err := parseLogLevel(c.LogLevel); err != nil {
+        return err
+    }
+    if err := validateLogFormat(c.LogFormat); err != nil {
+        return err
+    }
+
+    if c.ProbeVotes > 1 && (c.VoteThreshold <= 0 || c.VoteThreshold > 1) {
+        return fmt.Errorf("voteThreshold 应在 (0, 1] 之间，当前为 %v", c.VoteThreshold)
+    }
+    if c.Warmup && c.WarmupWorkers <= 0 {
+        return fmt.Errorf("warmupWorkers 应大于0，当前为 %d", c.WarmupWorkers)
+    }
+    if c.BannerGrab && c.BannerBytes <= 0 {
+        return fmt.Errorf("bannerBytes 应大于0，当前为 %d", c.BannerBytes)
+    }
+    if c.SaveResponse && c.SaveResponseLength <= 0 {
+        return fmt.Errorf("saveResponseLength 应大于0，当前为 %d", c.SaveResponseLength)
+    }
+    if c.Autoscale && (c.AutoscaleMin <= 0 || c.AutoscaleMin > c.AutoscaleMax) {
+        return fmt.Errorf("autoscaleMin 应大于0且不超过 autoscaleMax，当前为 %d/%d", c.AutoscaleMin, c.AutoscaleMax)
+    }
+    if c.MaxPlausibleTps > 0 && c.MinPlausibleTps > c.MaxPlausibleTps {
+        return fmt.Errorf("minPlausibleTps 不能大于 maxPlausibleTps，当前为 %v/%v", c.MinPlausibleTps, c.MaxPlausibleTps)
+    }
+    if err := validateHeaders(c.Headers); err != nil {
+        return err
+    }
+    if c.SkipPreviousFailures && c.previousResultsFile() == "" {
+        return fmt.Errorf("开启 skipPreviousFailures 时需要设置 previousResultsFile 或 priorResultsFile")
+    }
+
+    // 次数和数量类配置不能为负
+    for name, value := range map[string]int{
+        "sampleSize":     c.SampleSize,
+        "maxIdleConns":   c.MaxIdleConns,
+        "decodeRetries":  c.DecodeRetries,
+        "retryCount":     c.RetryCount,
+        "maxPerHost":     c.MaxPerHost,
+        "maxInFlight":    c.MaxInFlight,
+        "pipelineBuffer": c.PipelineBuffer,
+    } {
+        if value < 0 {
+            return fmt.Errorf("%s 不能为负数，当前为 %d", name, value)
+        }
+    }
+    return nil
+}
@@ -0,0 +1,61 @@
+package scan
+
+import (
+    "context"
+    "errors"
+    "fmt"
+    "math"
+    "sort"
+    "strings"
+)
+
+// 多次探测的模型列表未达成一致
+var errNoConsensus = errors.New("多次探测结果不一致")
+
+// 对同一主机探测 probeVotes 次，只有模型列表一致的次数达到 voteThreshold 比例时才认为服务存在
+// 未开启投票时等同于一次 getModels
+func (s *Scanner) voteModels(ctx context.Context, ip string, port int) ([]ModelInfo, map[string]string, error) {
+    votes := s.cfg.ProbeVotes
+    if votes <= 1 {
+        return s.getModels(ctx, ip, port)
+    }
+    needed := int(math.Ceil(float64(votes) * s.cfg.VoteThreshold))
+    if needed < 1 {
+        needed = 1
+    }
+
+    var (
+        lastErr error
+        headers map[string]string
+        best    string
+    )
+    tally := make(map[string]int)
+    lists := make(map[string][]ModelInfo)
+    for i := 0; i < votes; i++ {
+        models, h, err := s.getModels(ctx, ip, port)
+        if h != nil {
+            headers = h
+        }
+        if err != nil {
+            lastErr = err
+            continue
+        }
+        // 模型列表排序后作为投票键，顺序不同视为同一结果
+        sorted := modelNames(models)
+        sort.Strings(sorted)
+        key := strings.Join(sorted, "\n")
+        tally[key]++
+        lists[key] = models
+        if tally[key] > tally[best] {
+            best = key
+        }
+    }
+
+    if len(tally) == 0 {
+        return nil, headers, lastErr
+    }
+    if tally[best] < needed {
+        return nil, headers, fmt.Errorf("%w: 最多 %d/%d 次一致，需要 %d 次", errNoConsensus, tally[best], votes, needed)
+    }
+    return lists[best], headers, nil
+}
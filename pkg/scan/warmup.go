@@ -0,0 +1,75 @@
+package scan
+
+import (
+    "context"
+    "io"
+    "log/slog"
+    "net"
+    "sync"
+)
+
+// 预热模型，发送空提示词让服务端把模型加载进显存，避免加载时间计入首Token延迟
+// 预热同样受单主机并发上限约束，并由独立的有界工作池执行
+func (r *benchRun) warm(ip string, port int, modelName string) {
+    r.warmPool <- struct{}{}
+    defer func() { <-r.warmPool }()
+
+    s := r.s
+    if err := s.throttle.acquire(r.ctx, ip); err != nil {
+        return
+    }
+    defer s.throttle.release(ip, 0)
+    if err := s.inflight.acquire(r.ctx); err != nil {
+        return
+    }
+    defer s.inflight.release()
+
+    // 预热包括模型加载，只受总时长限制
+    ctx, cancel := context.WithTimeout(r.ctx, s.cfg.BenchTimeout)
+    defer cancel()
+    path, payload := "/api/generate", map[string]interface{}{
+        "model":  modelName,
+        "prompt": "",
+        "stream": false,
+    }
+    if s.cfg.BenchEndpoint == benchEndpointOpenAI {
+        // OpenAI 兼容接口没有只加载模型的请求，生成一个Token代替
+        path, payload = "/v1/chat/completions", map[string]interface{}{
+            "model":      modelName,
+            "messages":   []map[string]string{{"role": "user", "content": "hi"}},
+            "max_tokens": 1,
+        }
+    }
+    req, err := s.newJSONRequest(ctx, ip, port, path, payload)
+    if err != nil {
+        return
+    }
+    resp, err := s.benchClient.Do(req)
+    if err != nil {
+        slog.Warn("预热失败", "ip", ip, "model", modelName, "error", err)
+        return
+    }
+    io.Copy(io.Discard, resp.Body)
+    resp.Body.Close()
+    r.manifest.add("warmed", 1)
+}
+
+// 在计时测试前统一预热所有目标，全部完成后才开始计时阶段
+func (r *benchRun) warmAll(targets []benchTarget) {
+    r.prewarmed = true
+    var wg sync.WaitGroup
+    for _, t := range targets {
+        if r.drain.stopped() {
+            break
+        }
+        if net.ParseIP(t.ip) == nil || t.model == "" || r.cp.has(checkpointKey(hostPort(t.ip, t.port), t.model)) {
+            continue
+        }
+        wg.Add(1)
+        go func(t benchTarget) {
+            defer wg.Done()
+            r.warm(t.ip, t.port, t.model)
+        }(t)
+    }
+    wg.Wait()
+}
@@ -0,0 +1,70 @@
+package scan
+
+import (
+    "encoding/json"
+    "fmt"
+    "log/slog"
+    "strings"
+)
+
+// 关注模型通知，发现 watchModels 中的模型时向 webhookURL 发送消息
+// 与 Webhook 输出同样经 webhookPoster 在后台逐条发送，队列已满时直接丢弃，不阻塞检测
+type watchNotifier struct {
+    models []string
+    poster *webhookPoster
+}
+
+// 关注模型通知消息，text 和 content 分别用于 Slack 和 Discord 显示
+type watchMessage struct {
+    IP      string `json:"ip"`
+    Port    int    `json:"port"`
+    Model   string `json:"model"`
+    Text    string `json:"text"`
+    Content string `json:"content"`
+}
+
+// 未配置 webhookURL 或 watchModels 时返回nil
+func newWatchNotifier(url string, models []string) *watchNotifier {
+    if url == "" || len(models) == 0 {
+        return nil
+    }
+    return &watchNotifier{models: models, poster: newWebhookPoster(url, 0, 100)}
+}
+
+// 模型名称包含任一关注关键词时匹配，不区分大小写
+func (w *watchNotifier) matches(model string) bool {
+    model = strings.ToLower(model)
+    for _, keyword := range w.models {
+        if strings.Contains(model, strings.ToLower(keyword)) {
+            return true
+        }
+    }
+    return false
+}
+
+// 发现模型时调用，匹配关注列表则加入发送队列
+func (w *watchNotifier) notify(ip string, port int, model string) {
+    if w == nil || !w.matches(model) {
+        return
+    }
+    text := fmt.Sprintf("发现关注模型 %s: %s", model, hostPort(ip, port))
+    body, err := json.Marshal(watchMessage{IP: ip, Port: port, Model: model, Text: text, Content: text})
+    if err != nil {
+        return
+    }
+    w.poster.post(body)
+}
+
+// 发送队列中剩余的通知后退出
+func (w *watchNotifier) close() {
+    if w == nil {
+        return
+    }
+    failed, dropped := w.poster.close()
+    if failed > 0 {
+        slog.Warn("关注模型通知发送失败", "count", failed)
+    }
+    if dropped > 0 {
+        slog.Warn("关注模型通知队列已满，丢弃通知", "count", dropped)
+    }
+}